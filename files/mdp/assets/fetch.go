@@ -0,0 +1,96 @@
+//go:build ignore
+
+// Fetch downloads the client-side renderers of diagrams and math into
+// this directory, where mdp embeds them from. KaTeX's fonts are inlined
+// into its stylesheet, so the assets work offline as single files.
+//
+// Run it with go generate from the mdp directory.
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// Pinned versions of the renderers.
+const (
+	mermaidURL = "https://cdn.jsdelivr.net/npm/mermaid@10.6.0/dist/"
+	katexURL   = "https://cdn.jsdelivr.net/npm/katex@0.16.22/dist/"
+)
+
+var (
+	// fontRe matches the WOFF2 fonts the KaTeX stylesheet links to.
+	fontRe = regexp.MustCompile(`url\((fonts/[^)]+\.woff2)\)`)
+	// fallbackRe matches the WOFF and TrueType fallbacks of the fonts.
+	fallbackRe = regexp.MustCompile(`,url\(fonts/[^)]+\.(woff|ttf)\) format\("(woff|truetype)"\)`)
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	for name, url := range map[string]string{
+		"mermaid.min.js": mermaidURL + "mermaid.min.js",
+		"katex.min.js":   katexURL + "katex.min.js",
+	} {
+		data, err := get(url)
+		if err != nil {
+			return err
+		}
+		if err := save(name, data); err != nil {
+			return err
+		}
+	}
+
+	css, err := get(katexURL + "katex.min.css")
+	if err != nil {
+		return err
+	}
+
+	css = fallbackRe.ReplaceAll(css, nil)
+	css = fontRe.ReplaceAllFunc(css, func(m []byte) []byte {
+		if err != nil {
+			return m
+		}
+		var font []byte
+		if font, err = get(katexURL + string(fontRe.FindSubmatch(m)[1])); err != nil {
+			return m
+		}
+		return []byte("url(data:font/woff2;base64," + base64.StdEncoding.EncodeToString(font) + ")")
+	})
+	if err != nil {
+		return err
+	}
+
+	return save("katex.min.css", css)
+}
+
+// get downloads url.
+func get(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// save writes the asset into the assets directory.
+func save(name string, data []byte) error {
+	fmt.Println("assets/" + name)
+	return os.WriteFile(filepath.Join("assets", name), data, 0644)
+}
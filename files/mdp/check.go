@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/russross/blackfriday/v2"
+)
+
+// headingLineRe matches ATX headings and the text line of setext headings.
+var headingLineRe = regexp.MustCompile(`(?m)^[ >]*#+[ \t]|^.+\n[ \t]*(=+|-+)[ \t]*$`)
+
+// problem is an issue found in a markdown file by the check mode.
+type problem struct {
+	file string
+	line int
+	msg  string
+}
+
+func (p problem) String() string {
+	return fmt.Sprintf("%s:%d: %s", p.file, p.line, p.msg)
+}
+
+// checker checks markdown files, keeping the anchors
+// of the markdown files linked to.
+type checker struct {
+	opts    renderOptions
+	anchors map[string]map[string]bool // heading ids, by markdown file
+}
+
+// newChecker returns a checker parsing markdown with opts.
+func newChecker(opts renderOptions) *checker {
+	return &checker{
+		opts:    opts,
+		anchors: make(map[string]map[string]bool),
+	}
+}
+
+// checkFiles checks the markdown files, writing the problems found to
+// out. A directory is checked by every markdown file under it, but
+// for hidden ones. It returns ErrCheckFailed if there were problems.
+func checkFiles(names []string, opts renderOptions, out io.Writer) error {
+	c := newChecker(opts)
+	count := 0
+
+	for _, name := range names {
+		err := filepath.WalkDir(name, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() {
+				if p != name && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			// Files given by name are checked even without a markdown extension.
+			if p != name && (!isMarkdown(p) || strings.HasPrefix(d.Name(), ".")) {
+				return nil
+			}
+
+			input, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+
+			problems, err := c.check(p, input, filepath.Dir(p))
+			if err != nil {
+				return err
+			}
+			for _, pb := range problems {
+				fmt.Fprintln(out, pb)
+			}
+			count += len(problems)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if count > 0 {
+		return fmt.Errorf("%w: %d problems found", ErrCheckFailed, count)
+	}
+
+	return nil
+}
+
+// check returns the problems of the markdown file fname: relative links
+// to missing files or anchors, missing images, duplicate headings and
+// headings more than one level deeper than the previous one. Relative
+// paths are resolved from baseDir.
+func (c *checker) check(fname string, input []byte, baseDir string) ([]problem, error) {
+	_, body, err := splitFrontMatter(input)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+
+	// The front matter lines come before the body.
+	loc := &locator{
+		src:   body,
+		first: bytes.Count(input[:len(input)-len(body)], []byte("\n")) + 1,
+	}
+
+	doc, _ := parseMarkdown(body, c.opts)
+	anchors := headingAnchors(doc)
+
+	var problems []problem
+	report := func(line int, format string, args ...any) {
+		problems = append(problems, problem{file: fname, line: line, msg: fmt.Sprintf(format, args...)})
+	}
+
+	headings := make(map[string]int) // line of each heading text
+	level := 0
+
+	doc.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if !entering {
+			return blackfriday.GoToNext
+		}
+
+		switch node.Type {
+		case blackfriday.Heading:
+			if node.IsTitleblock {
+				return blackfriday.SkipChildren
+			}
+			line := loc.heading(firstText(node))
+
+			if level > 0 && node.Level > level+1 {
+				report(line, "heading level jumps from h%d to h%d", level, node.Level)
+			}
+			level = node.Level
+
+			text := strings.TrimSpace(plainText(node))
+			if first, ok := headings[strings.ToLower(text)]; ok {
+				report(line, "duplicate heading %q, first at line %d", text, first)
+			} else {
+				headings[strings.ToLower(text)] = line
+			}
+		case blackfriday.Link:
+			dest := string(node.LinkData.Destination)
+			if node.LinkData.NoteID != 0 || !isRelative(dest) {
+				return blackfriday.GoToNext
+			}
+			if msg := c.checkLink(dest, baseDir, anchors); msg != "" {
+				report(loc.line(dest), "%s", msg)
+			}
+		case blackfriday.Image:
+			dest := string(node.LinkData.Destination)
+			if !isRelative(dest) {
+				return blackfriday.SkipChildren
+			}
+			if _, err := os.Stat(assetPath(baseDir, dest)); err != nil {
+				report(loc.line(dest), "image %s not found", dest)
+			}
+			return blackfriday.SkipChildren
+		}
+
+		return blackfriday.GoToNext
+	})
+
+	return problems, nil
+}
+
+// checkLink returns the problem of the relative link, or an empty string
+// if the file it points to exists, with the anchor if it's a markdown file.
+// Anchors of the page itself are in anchors. As headings only get ids with
+// the toc option, anchors of markdown files can't be found without it.
+func (c *checker) checkLink(dest, baseDir string, anchors map[string]bool) string {
+	target, anchor := dest, ""
+	if i := strings.IndexByte(dest, '#'); i >= 0 {
+		target, anchor = dest[:i], dest[i+1:]
+		if a, err := url.PathUnescape(anchor); err == nil {
+			anchor = a
+		}
+	}
+	if i := strings.IndexByte(target, '?'); i >= 0 {
+		target = target[:i]
+	}
+
+	if target == "" {
+		if anchor != "" && !c.opts.toc {
+			return fmt.Sprintf("anchor #%s not found, headings only get ids with -toc", anchor)
+		}
+		if anchor != "" && !anchors[anchor] {
+			return fmt.Sprintf("anchor #%s not found", anchor)
+		}
+		return ""
+	}
+
+	fname := assetPath(baseDir, target)
+	if _, err := os.Stat(fname); err != nil {
+		return fmt.Sprintf("link to missing file %s", target)
+	}
+
+	if anchor == "" || !isMarkdown(fname) {
+		return ""
+	}
+	if !c.opts.toc {
+		return fmt.Sprintf("anchor #%s not found in %s, headings only get ids with -toc", anchor, target)
+	}
+
+	ids, err := c.fileAnchors(fname)
+	if err != nil {
+		return fmt.Sprintf("can't read anchors of %s: %s", target, err)
+	}
+	if !ids[anchor] {
+		return fmt.Sprintf("anchor #%s not found in %s", anchor, target)
+	}
+
+	return ""
+}
+
+// fileAnchors returns the heading ids of the markdown file.
+func (c *checker) fileAnchors(fname string) (map[string]bool, error) {
+	if ids, ok := c.anchors[fname]; ok {
+		return ids, nil
+	}
+
+	input, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	_, body, err := splitFrontMatter(input)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, _ := parseMarkdown(body, c.opts)
+	c.anchors[fname] = headingAnchors(doc)
+
+	return c.anchors[fname], nil
+}
+
+// headingAnchors returns the ids given to the headings of the document.
+func headingAnchors(doc *blackfriday.Node) map[string]bool {
+	ids := make(map[string]bool)
+	for _, h := range setHeadingIDs(doc, 6) {
+		ids[h.id] = true
+	}
+
+	return ids
+}
+
+// firstText returns the first text of the node, as written in the source.
+func firstText(node *blackfriday.Node) string {
+	var text string
+
+	node.Walk(func(n *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if n.Type == blackfriday.Text || n.Type == blackfriday.Code {
+			if t := strings.TrimSpace(string(n.Literal)); t != "" {
+				text = t
+				return blackfriday.Terminate
+			}
+		}
+		return blackfriday.GoToNext
+	})
+
+	return text
+}
+
+// locator finds the lines of the nodes of a markdown file, which
+// blackfriday doesn't keep, by searching their text in the source
+// in document order.
+type locator struct {
+	src   []byte
+	first int // line number of the first line of src
+	pos   int // offset of the last node found
+}
+
+// line returns the line of the next occurrence of text in the source,
+// or of an earlier one, as for link reference definitions. If text
+// isn't found, it's the line of the last node found.
+func (l *locator) line(text string) int {
+	i := -1
+	if text != "" {
+		if i = bytes.Index(l.src[l.pos:], []byte(text)); i >= 0 {
+			i += l.pos
+			l.pos = i + len(text)
+		} else {
+			i = bytes.Index(l.src, []byte(text))
+		}
+	}
+	if i < 0 {
+		i = l.pos
+	}
+
+	return l.first + bytes.Count(l.src[:i], []byte("\n"))
+}
+
+// heading returns the line of the next heading holding text. As the
+// text of headings can differ from their source, with emoji or math,
+// it's otherwise the line of the next heading in the source.
+func (l *locator) heading(text string) int {
+	var found []int
+	for _, m := range headingLineRe.FindAllIndex(l.src[l.pos:], -1) {
+		if found == nil {
+			found = m
+		}
+		if text != "" && strings.Contains(firstLine(l.src[l.pos+m[0]:]), text) {
+			found = m
+			break
+		}
+	}
+	if found == nil {
+		return l.line("")
+	}
+	i := l.pos + found[0]
+	l.pos += found[1]
+
+	return l.first + bytes.Count(l.src[:i], []byte("\n"))
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckFiles(t *testing.T) {
+	dir := createSite(t, map[string]string{
+		"docs/guide.md": `---
+title: Guide
+---
+# Guide
+
+See [setup](setup.md#install), [missing](nope.md), [bad anchor](setup.md#nope) and [local](#usage).
+
+![logo](img/logo.png)
+![gone](img/gone.png)
+
+### Deep
+
+## Usage :rocket:
+
+## Usage :rocket:
+
+[top](#guide) [broken](#nothing) [remote](https://example.com/x.md) [ref][r]
+
+[r]: missing.md
+`,
+		"docs/setup.md":     "# Setup\n\n## Install\n\nBack to the [guide](guide.md#usage).\n",
+		"docs/img/logo.png": "png",
+		".drafts/broken.md": "[x](nothing.md)\n",
+	})
+	guide := filepath.Join(dir, "docs", "guide.md")
+
+	expOut := strings.Join([]string{
+		guide + ":6: link to missing file nope.md",
+		guide + ":6: anchor #nope not found in setup.md",
+		guide + ":9: image img/gone.png not found",
+		guide + ":11: heading level jumps from h1 to h3",
+		guide + ":15: duplicate heading \"Usage 🚀\", first at line 13",
+		guide + ":17: anchor #nothing not found",
+		guide + ":19: link to missing file missing.md",
+	}, "\n") + "\n"
+
+	testCases := []struct {
+		name string
+		path string
+	}{
+		{name: "File", path: guide},
+		{name: "Dir", path: dir},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := checkFiles([]string{tc.path}, renderOptions{extensions: allExtensions, toc: true}, &out)
+			if !errors.Is(err, ErrCheckFailed) {
+				t.Fatalf("Expected error %q, got %q", ErrCheckFailed, err)
+			}
+			if !strings.Contains(err.Error(), "7 problems") {
+				t.Errorf("Expected 7 problems, got %q", err)
+			}
+			if out.String() != expOut {
+				t.Errorf("Expected output:\n%s\ngot:\n%s", expOut, out.String())
+			}
+		})
+	}
+}
+
+func TestCheckFilesClean(t *testing.T) {
+	dir := createSite(t, map[string]string{
+		"README.md": "# Project\n\n## Install\n\n```sh\n# not a heading\n```\n\n" +
+			"See [install](#install), [the todo](notes.md#todo) and [Go](https://go.dev).\n",
+		"notes.md": "# Notes\n\n## TODO\n",
+	})
+
+	var out bytes.Buffer
+	if err := checkFiles([]string{dir}, renderOptions{toc: true}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no problems, got %q", out.String())
+	}
+}
+
+func TestCheckFilesNoTOC(t *testing.T) {
+	dir := createSite(t, map[string]string{
+		"README.md": "# Project\n\n## Second\n\nSee [second](#second), [notes](notes.md#todo) and [all notes](notes.md).\n",
+		"notes.md":  "# Notes\n\n## TODO\n",
+	})
+	readme := filepath.Join(dir, "README.md")
+
+	expOut := readme + ":5: anchor #second not found, headings only get ids with -toc\n" +
+		readme + ":5: anchor #todo not found in notes.md, headings only get ids with -toc\n"
+
+	var out bytes.Buffer
+	err := checkFiles([]string{readme}, renderOptions{}, &out)
+	if !errors.Is(err, ErrCheckFailed) {
+		t.Fatalf("Expected error %q, got %q", ErrCheckFailed, err)
+	}
+	if out.String() != expOut {
+		t.Errorf("Expected output:\n%s\ngot:\n%s", expOut, out.String())
+	}
+}
+
+func TestCheckHeadingLines(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  string
+		expect string
+	}{
+		{name: "MentionedBefore", input: "# Title\n\nSee the Setup section.\n\n## Setup\n\n## Setup\n",
+			expect: ":7: duplicate heading \"Setup\", first at line 5\n"},
+		{name: "Setext", input: "Title\n=====\n\nThe Usage notes.\n\nUsage\n-----\n\n## Usage\n",
+			expect: ":9: duplicate heading \"Usage\", first at line 6\n"},
+		{name: "Quoted", input: "# Title\n\nSee Deep below.\n\n> #### Deep\n",
+			expect: ":5: heading level jumps from h1 to h4\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := createSite(t, map[string]string{"doc.md": tc.input})
+			fname := filepath.Join(dir, "doc.md")
+
+			var out bytes.Buffer
+			err := checkFiles([]string{fname}, renderOptions{}, &out)
+			if !errors.Is(err, ErrCheckFailed) {
+				t.Fatalf("Expected error %q, got %q", ErrCheckFailed, err)
+			}
+			if out.String() != fname+tc.expect {
+				t.Errorf("Expected output:\n%s\ngot:\n%s", fname+tc.expect, out.String())
+			}
+		})
+	}
+}
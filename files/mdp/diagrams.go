@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/russross/blackfriday/v2"
+)
+
+//go:generate go run assets/fetch.go
+
+// bundledAssets holds the client-side renderers of diagrams and math,
+// fetched into the assets directory by go generate, so previews work offline.
+//
+//go:embed assets/*.js assets/*.css
+var bundledAssets embed.FS
+
+// assets is the file system the renderers are read from.
+var assets fs.FS = bundledAssets
+
+// Renderers bundled in the assets directory.
+const (
+	mermaidJS = "assets/mermaid.min.js"
+	katexJS   = "assets/katex.min.js"
+	katexCSS  = "assets/katex.min.css" // with its fonts inlined
+)
+
+// Markup of the diagrams and math, rendered in the browser.
+const (
+	mermaidInit = "<script>mermaid.initialize({startOnLoad: true});</script>\n"
+	katexInit   = `<script>
+document.querySelectorAll(".math").forEach(function (el) {
+  katex.render(el.textContent, el, {displayMode: el.classList.contains("math-display"), throwOnError: false});
+});
+</script>
+`
+)
+
+// Private use characters delimiting the index of the
+// math replaced by protectMath, which markdown leaves alone.
+const (
+	mathStart = "\uE000"
+	mathEnd   = "\uE001"
+)
+
+var (
+	// diagramClassRe matches the classes of the diagram and math markup.
+	diagramClassRe = regexp.MustCompile(`^(mermaid|math math-(inline|display))$`)
+	// fenceRe matches the opening fence of a fenced code block.
+	fenceRe = regexp.MustCompile("^(```+|~~~+)")
+	// mathRe matches the placeholders of protected math.
+	mathRe = regexp.MustCompile(mathStart + `(\d+)` + mathEnd)
+)
+
+// mathSpan is TeX math taken out of the markdown.
+type mathSpan struct {
+	tex     string
+	display bool // $$ math, on its own line
+}
+
+// protectMath replaces the $ and $$ delimited math of the markdown
+// input, outside of code, by placeholders, so its underscores, asterisks
+// and backslashes aren't parsed as markdown. An escaped \$ is a dollar.
+func protectMath(input []byte) ([]byte, []mathSpan) {
+	var out, text []byte
+	var spans []mathSpan
+	fence := ""
+
+	for _, line := range bytes.SplitAfter(input, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " ")
+		indented := len(line)-len(trimmed) >= 4
+
+		if fence == "" {
+			if m := fenceRe.Find(trimmed); m != nil && !indented {
+				out = append(out, scanMath(text, &spans)...)
+				text, fence = nil, string(m)
+				out = append(out, line...)
+				continue
+			}
+			text = append(text, line...)
+			continue
+		}
+
+		out = append(out, line...)
+		if !indented && bytes.HasPrefix(trimmed, []byte(fence)) &&
+			len(bytes.TrimSpace(bytes.TrimLeft(trimmed, fence[:1]))) == 0 {
+			fence = ""
+		}
+	}
+
+	return append(out, scanMath(text, &spans)...), spans
+}
+
+// scanMath replaces the math of markdown text without fenced code
+// blocks by placeholders, adding it to spans. Code spans are skipped.
+func scanMath(text []byte, spans *[]mathSpan) []byte {
+	var out []byte
+
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case '\\':
+			if i+1 < len(text) && text[i+1] == '$' {
+				out, i = append(out, '$'), i+2
+				continue
+			}
+			if i+1 < len(text) {
+				out, i = append(out, text[i:i+2]...), i+2
+				continue
+			}
+		case '`':
+			n := 1
+			for i+n < len(text) && text[i+n] == '`' {
+				n++
+			}
+			end := i + n + codeSpanEnd(text[i+n:], n)
+			out, i = append(out, text[i:end]...), end
+			continue
+		case '$':
+			if span, n, ok := mathAt(text[i:]); ok {
+				out = append(out, mathStart+strconv.Itoa(len(*spans))+mathEnd...)
+				*spans = append(*spans, span)
+				i += n
+				continue
+			}
+		}
+
+		out, i = append(out, text[i]), i+1
+	}
+
+	return out
+}
+
+// codeSpanEnd returns the end of the code span whose opening run of
+// n backticks text follows, or 0 if it isn't closed.
+func codeSpanEnd(text []byte, n int) int {
+	for i := 0; i < len(text); {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+		run := 1
+		for i+run < len(text) && text[i+run] == '`' {
+			run++
+		}
+		if run == n {
+			return i + run
+		}
+		i += run
+	}
+
+	return 0
+}
+
+// mathAt returns the math text starts with, and its length with the
+// delimiters. Inline math doesn't start or end with a space, can't
+// hold a blank line or a code span and isn't followed by a digit, so
+// prices aren't math.
+func mathAt(text []byte) (mathSpan, int, bool) {
+	if bytes.HasPrefix(text, []byte("$$")) {
+		end := bytes.Index(text[2:], []byte("$$"))
+		if end < 0 || len(bytes.TrimSpace(text[2:2+end])) == 0 {
+			return mathSpan{}, 0, false
+		}
+		tex := strings.TrimSpace(string(text[2 : 2+end]))
+		return mathSpan{tex: tex, display: true}, end + 4, true
+	}
+
+	if len(text) < 2 || text[1] == ' ' || text[1] == '\t' || text[1] == '\n' {
+		return mathSpan{}, 0, false
+	}
+
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '`':
+			return mathSpan{}, 0, false
+		case '\n':
+			if i+1 < len(text) && text[i+1] == '\n' {
+				return mathSpan{}, 0, false
+			}
+		case '$':
+			prev := text[i-1]
+			if prev == ' ' || prev == '\t' || prev == '\n' ||
+				(i+1 < len(text) && text[i+1] >= '0' && text[i+1] <= '9') {
+				continue
+			}
+			return mathSpan{tex: string(text[1:i])}, i + 1, true
+		}
+	}
+
+	return mathSpan{}, 0, false
+}
+
+// restoreMath replaces the math placeholders of the rendered HTML by
+// the markup the math renderer looks for. Math inside a tag, like in
+// the alt text of an image, is restored as its source.
+func restoreMath(output []byte, spans []mathSpan) []byte {
+	var out []byte
+	inTag := false
+	last := 0
+
+	for _, loc := range mathRe.FindAllSubmatchIndex(output, -1) {
+		for _, c := range output[last:loc[0]] {
+			switch c {
+			case '<':
+				inTag = true
+			case '>':
+				inTag = false
+			}
+		}
+		out = append(out, output[last:loc[0]]...)
+		last = loc[1]
+
+		i, err := strconv.Atoi(string(output[loc[2]:loc[3]]))
+		if err != nil || i >= len(spans) {
+			continue
+		}
+		span := spans[i]
+		tex := html.EscapeString(span.tex)
+
+		switch {
+		case inTag && span.display:
+			out = append(out, "$$"+tex+"$$"...)
+		case inTag:
+			out = append(out, "$"+tex+"$"...)
+		case span.display:
+			out = append(out, `<span class="math math-display">`+tex+"</span>"...)
+		default:
+			out = append(out, `<span class="math math-inline">`+tex+"</span>"...)
+		}
+	}
+
+	return append(out, output[last:]...)
+}
+
+// setDiagrams replaces the mermaid code blocks of the document by the
+// markup mermaid renders, and math code blocks by display math, if
+// enabled by opts. It reports whether the document has any of them.
+func setDiagrams(doc *blackfriday.Node, opts renderOptions) (mermaid, math bool) {
+	var blocks []*blackfriday.Node
+
+	doc.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if entering && node.Type == blackfriday.CodeBlock {
+			blocks = append(blocks, node)
+		}
+		return blackfriday.GoToNext
+	})
+
+	for _, node := range blocks {
+		lang := strings.Fields(string(node.Info))
+		if len(lang) == 0 {
+			continue
+		}
+
+		var markup string
+		switch {
+		case lang[0] == "mermaid" && opts.mermaid:
+			markup, mermaid = `<pre class="mermaid">`+html.EscapeString(string(node.Literal))+"</pre>", true
+		case lang[0] == "math" && opts.math:
+			markup, math = `<div class="math math-display">`+html.EscapeString(string(node.Literal))+"</div>", true
+		default:
+			continue
+		}
+
+		block := blackfriday.NewNode(blackfriday.HTMLBlock)
+		block.Literal = []byte(markup)
+		node.InsertBefore(block)
+		node.Unlink()
+	}
+
+	return mermaid, math
+}
+
+// renderers returns the scripts and stylesheet rendering the diagrams and
+// math of a page in the browser, read from the bundled assets.
+func renderers(mermaid, math bool) (scripts, css []byte, err error) {
+	if mermaid {
+		js, err := readAsset(mermaidJS)
+		if err != nil {
+			return nil, nil, err
+		}
+		scripts = append(scripts, inlineScript(js)...)
+		scripts = append(scripts, mermaidInit...)
+	}
+
+	if math {
+		js, err := readAsset(katexJS)
+		if err != nil {
+			return nil, nil, err
+		}
+		if css, err = readAsset(katexCSS); err != nil {
+			return nil, nil, err
+		}
+		scripts = append(scripts, inlineScript(js)...)
+		scripts = append(scripts, katexInit...)
+	}
+
+	return scripts, css, nil
+}
+
+// readAsset reads a bundled renderer.
+func readAsset(name string) ([]byte, error) {
+	data, err := fs.ReadFile(assets, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s, run go generate to bundle it", ErrMissingAsset, name)
+	}
+
+	return data, err
+}
+
+// inlineScript returns a script tag running js, escaping
+// the closing tags it may hold in strings.
+func inlineScript(js []byte) []byte {
+	js = bytes.ReplaceAll(js, []byte("</script"), []byte(`<\/script`))
+
+	return []byte(fmt.Sprintf("<script>\n%s\n</script>\n", bytes.TrimSpace(js)))
+}
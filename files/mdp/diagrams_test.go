@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// fakeAssets replaces the bundled renderers for the test.
+func fakeAssets(t *testing.T) {
+	t.Helper()
+
+	orig := assets
+	assets = fstest.MapFS{
+		mermaidJS: {Data: []byte("var mermaid = {}; // </script>\n")},
+		katexJS:   {Data: []byte("var katex = {};\n")},
+		katexCSS:  {Data: []byte(".katex { font: inherit; }\n")},
+	}
+	t.Cleanup(func() { assets = orig })
+}
+
+func TestDiagrams(t *testing.T) {
+	fakeAssets(t)
+
+	testCases := []struct {
+		name     string
+		opts     renderOptions
+		input    string
+		expect   []string
+		unexpect []string
+	}{
+		{name: "Mermaid", opts: renderOptions{mermaid: true},
+			input: "```mermaid\ngraph TD;\n  A-->B;\n```\n",
+			expect: []string{"<pre class=\"mermaid\">graph TD;\n  A--&gt;B;\n</pre>",
+				"<script>\nvar mermaid = {}; // <\\/script>\n</script>", "mermaid.initialize("},
+			unexpect: []string{"katex", "<style>"}},
+		{name: "MermaidDisabled",
+			input:    "```mermaid\ngraph TD;\n```\n",
+			expect:   []string{"<pre><code>graph TD;\n</code></pre>"},
+			unexpect: []string{"<script", `class="mermaid"`}},
+		{name: "InlineMath", opts: renderOptions{math: true},
+			input: "Let $x *y* z_1 < c$ hold.\n",
+			expect: []string{`Let <span class="math math-inline">x *y* z_1 &lt; c</span> hold.`,
+				"var katex = {};", "katex.render(", "<style>\n.katex { font: inherit; }\n</style>"},
+			unexpect: []string{"<em>", "mermaid"}},
+		{name: "DisplayMath", opts: renderOptions{math: true},
+			input:  "$$\n\\sum_{i=1}^n x_i\n$$\n",
+			expect: []string{`<span class="math math-display">\sum_{i=1}^n x_i</span>`}},
+		{name: "MathBlock", opts: renderOptions{math: true},
+			input:  "```math\nE = mc^2\n```\n",
+			expect: []string{"<div class=\"math math-display\">E = mc^2\n</div>", "katex.render("}},
+		{name: "NotMath", opts: renderOptions{math: true},
+			input: "It costs $5 or $10, \\$x\\$ and `$y$`.\n\n```sh\necho $HOME$PATH\n```\n",
+			expect: []string{"It costs $5 or $10, $x$ and <code>$y$</code>.",
+				"echo $HOME$PATH"},
+			unexpect: []string{"math-inline", "<script"}},
+		{name: "MathInAltText", opts: renderOptions{math: true, policy: policyNone},
+			input:  "![$x^2$](plot.png)\n",
+			expect: []string{`alt="$x^2$"`}},
+		{name: "MathInHeading", opts: renderOptions{math: true, toc: true, tocDepth: 3},
+			input:  "# Energy $E$\n",
+			expect: []string{`Energy <span class="math math-inline">E</span></h1>`, `">Energy <span class="math math-inline">E</span></a>`}},
+		{name: "MathDisabled",
+			input:    "Let $x *y* z$ hold.\n",
+			expect:   []string{"Let $x <em>y</em> z$ hold."},
+			unexpect: []string{"math-inline", "<script"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := parseContent([]byte(tc.input), "", "", tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, exp := range tc.expect {
+				if !strings.Contains(string(result), exp) {
+					t.Errorf("Expected %q, got:\n%s", exp, result)
+				}
+			}
+			for _, unexp := range tc.unexpect {
+				if strings.Contains(string(result), unexp) {
+					t.Errorf("Expected no %q, got:\n%s", unexp, result)
+				}
+			}
+		})
+	}
+}
+
+func TestDiagramsMissingAsset(t *testing.T) {
+	orig := assets
+	assets = fstest.MapFS{}
+	t.Cleanup(func() { assets = orig })
+
+	_, err := parseContent([]byte("```mermaid\ngraph TD;\n```\n"), "", "", renderOptions{mermaid: true})
+	if !errors.Is(err, ErrMissingAsset) {
+		t.Fatalf("Expected error %q, got %q", ErrMissingAsset, err)
+	}
+
+	if _, err := parseContent([]byte("No diagrams.\n"), "", "", renderOptions{mermaid: true, math: true}); err != nil {
+		t.Errorf("Expected no error without diagrams, got %q", err)
+	}
+}
+
+func TestBundledAssets(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expect   string
+		unexpect string
+	}{
+		{name: mermaidJS, expect: ".mermaid=", unexpect: "</script>"},
+		{name: katexJS, expect: ".katex=", unexpect: "</script>"},
+		{name: katexCSS, expect: "src:url(data:font/woff2;base64,", unexpect: "url(fonts/"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := fs.ReadFile(bundledAssets, tc.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), tc.expect) {
+				t.Errorf("Expected %s to contain %q", tc.name, tc.expect)
+			}
+			if strings.Contains(string(data), tc.unexpect) {
+				t.Errorf("Expected %s not to contain %q", tc.name, tc.unexpect)
+			}
+		})
+	}
+
+	input := "```mermaid\ngraph TD;\n```\n\nLet $x$ hold.\n"
+	if _, err := parseContent([]byte(input), "", "", renderOptions{mermaid: true, math: true}); err != nil {
+		t.Errorf("Expected the bundled renderers to be found, got %q", err)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import "errors"
+
+var (
+	ErrInvalidStyle     = errors.New("invalid highlighting style")
+	ErrInvalidFormat    = errors.New("invalid output format")
+	ErrInvalidPolicy    = errors.New("invalid sanitization policy")
+	ErrInvalidExtension = errors.New("invalid markdown extension")
+	ErrMissingAsset     = errors.New("missing bundled renderer")
+	ErrCheckFailed      = errors.New("check failed")
+	ErrUnsupportedOS    = errors.New("os not supported")
+	ErrInvalidTheme     = errors.New("invalid theme")
+	ErrInvalidLayout    = errors.New("missing template layout")
+	ErrInvalidDate      = errors.New("invalid source date epoch")
+	ErrUnsupportedText  = errors.New("characters not supported in pdf")
+)
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// createPNG writes a small PNG image to fname, returning its contents.
+func createPNG(t *testing.T, fname string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fname, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestRunStandalone(t *testing.T) {
+	dir := t.TempDir()
+	img := createPNG(t, filepath.Join(dir, "my logo.png"))
+
+	md := "# Title\n\n![logo](my%20logo.png) ![remote](https://example.com/a.png)\n"
+	if err := os.WriteFile(filepath.Join(dir, "doc.md"), []byte(md), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "style.css"), []byte("h1 { color: red }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := `<html><head><link rel="stylesheet" href="style.css"></head><body>{{ .Body }}</body></html>`
+	tFname := filepath.Join(dir, "tmpl.html")
+	if err := os.WriteFile(tFname, []byte(tmpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outName := filepath.Join(dir, "out.html")
+
+	var mockStdout bytes.Buffer
+	if err := run(filepath.Join(dir, "doc.md"), tFname, outName, formatStandalone, renderOptions{}, &mockStdout, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.TrimSpace(mockStdout.String()) != outName {
+		t.Errorf("Expected output file %q, got %q", outName, mockStdout.String())
+	}
+
+	result, err := os.ReadFile(outName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{
+		`src="data:image/png;base64,` + base64.StdEncoding.EncodeToString(img) + `"`,
+		`src="https://example.com/a.png"`,
+		"<style>\nh1 { color: red }\n</style>",
+	}
+	for _, exp := range expect {
+		if !strings.Contains(string(result), exp) {
+			t.Errorf("Expected result to contain %q, got:\n%s", exp, result)
+		}
+	}
+}
+
+func TestRunStandaloneMissingAsset(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "doc.md"), []byte("![missing](missing.png)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := run(filepath.Join(dir, "doc.md"), "", filepath.Join(dir, "out.html"), formatStandalone,
+		renderOptions{}, &bytes.Buffer{}, nil, false)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected error %q, got %q", os.ErrNotExist, err)
+	}
+}
+
+func TestRunPDF(t *testing.T) {
+	dir := t.TempDir()
+	createPNG(t, filepath.Join(dir, "logo.png"))
+
+	md := "---\ntitle: Report\n---\n# Title\n\nSome *emphasized*, **strong** and `code` text, " +
+		"with a [link](https://example.com) and ünïcode.\n\n![logo](logo.png)\n\n" +
+		"1. one\n2. two\n   * nested\n\n> quoted\n\n```\ncode block\n```\n\n" +
+		"| a | b |\n|---|---|\n| 1 | 2 |\n\n---\n"
+	if err := os.WriteFile(filepath.Join(dir, "doc.md"), []byte(md), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outName := filepath.Join(dir, "out.pdf")
+	if err := run(filepath.Join(dir, "doc.md"), "", outName, formatPDF, renderOptions{}, &bytes.Buffer{}, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := os.ReadFile(outName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(result, []byte("%PDF-")) {
+		t.Errorf("Expected a PDF document")
+	}
+	if !bytes.Contains(result, []byte("/Title")) {
+		t.Errorf("Expected the front matter title in the PDF")
+	}
+}
+
+func TestExportPDFDeterministic(t *testing.T) {
+	opts := renderOptions{deterministic: true, date: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)}
+	input := []byte("---\ntitle: Report\n---\n# Title\n\nSome text.\n")
+
+	first, err := export(input, "doc.md", "", ".", formatPDF, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := export(input, "doc.md", "", ".", formatPDF, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(first, second) {
+		t.Error("Expected deterministic PDFs to be identical")
+	}
+	if !bytes.Contains(first, []byte("/CreationDate (D:20231114221320)")) {
+		t.Errorf("Expected the PDF to be dated %s", opts.date)
+	}
+}
+
+func TestExportPDFUnicode(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  string
+		expErr error
+		expMsg string
+	}{
+		{name: "Latin", input: "# Héllo\n\nŁódź, *Ελληνικά* and **Привет**.\n"},
+		{name: "Code", input: "Some `Привет` code.\n\n```\nπ := 3.14\n```\n"},
+		{name: "Unsupported", input: "# Héllo 日本語 👍\n", expErr: ErrUnsupportedText,
+			expMsg: `'日' (U+65E5), '本' (U+672C), '語' (U+8A9E), '👍' (U+1F44D)`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := export([]byte(tc.input), "doc.md", "", ".", formatPDF, renderOptions{})
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Fatalf("Expected error %q, got %q", tc.expErr, err)
+				}
+				if !strings.Contains(err.Error(), tc.expMsg) {
+					t.Errorf("Expected error to list %s, got %q", tc.expMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Contains(result, []byte("/BaseFont /utf8dejavu")) {
+				t.Error("Expected the UTF-8 font to be embedded")
+			}
+		})
+	}
+}
+
+func TestRunInvalidFormat(t *testing.T) {
+	err := run(inputFile, "", "", "docx", renderOptions{}, &bytes.Buffer{}, nil, false)
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected error %q, got %q", ErrInvalidFormat, err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// metaKeyRe matches a line starting with a YAML or TOML key, telling
+// front matter that fails to parse from markdown between rules.
+var metaKeyRe = regexp.MustCompile(`^["']?[\w.-]+["']?[ \t]*[:=]`)
+
+// frontMatter holds the metadata set at the top of a markdown file,
+// between --- lines for YAML or +++ lines for TOML.
+type frontMatter struct {
+	Title  string
+	Author string
+	Date   string
+	Tags   []string
+	Meta   map[string]any // every key, including the ones above
+}
+
+// splitFrontMatter parses the front matter at the start of input, if any,
+// returning it with the rest of the input. Input without a closing
+// delimiter has no front matter, the opening line being a rule, and
+// neither has a block that doesn't parse unless it starts with a key.
+func splitFrontMatter(input []byte) (frontMatter, []byte, error) {
+	var fm frontMatter
+
+	var (
+		unmarshal func([]byte, any) error
+		delims    []string
+	)
+
+	switch firstLine(input) {
+	case "---":
+		unmarshal = yaml.Unmarshal
+		delims = []string{"---", "..."}
+	case "+++":
+		unmarshal = toml.Unmarshal
+		delims = []string{"+++"}
+	default:
+		return fm, input, nil
+	}
+
+	// Look for the closing delimiter, line by line.
+	rest := input[bytes.IndexByte(input, '\n')+1:]
+	for off := 0; off < len(rest); {
+		end := bytes.IndexByte(rest[off:], '\n')
+		next := len(rest)
+		if end >= 0 {
+			end += off
+			next = end + 1
+		} else {
+			end = len(rest)
+		}
+
+		line := strings.TrimRight(string(rest[off:end]), " \t\r")
+		for _, d := range delims {
+			if line != d {
+				continue
+			}
+
+			if err := unmarshal(rest[:off], &fm.Meta); err != nil {
+				// A block that isn't a mapping, such as slides after
+				// a leading rule, is part of the body.
+				if !metaKeyRe.MatchString(firstLine(bytes.TrimLeft(rest[:off], " \t\r\n"))) {
+					return frontMatter{}, input, nil
+				}
+				return fm, nil, fmt.Errorf("parsing front matter: %w", err)
+			}
+			fm.fill()
+			return fm, rest[next:], nil
+		}
+
+		off = next
+	}
+
+	return fm, input, nil
+}
+
+// firstLine returns the first line of input, without trailing spaces.
+func firstLine(input []byte) string {
+	if i := bytes.IndexByte(input, '\n'); i >= 0 {
+		input = input[:i]
+	}
+
+	return strings.TrimRight(string(input), " \t\r")
+}
+
+// fill sets the well-known fields from the keys in fm.Meta.
+func (fm *frontMatter) fill() {
+	if fm.Meta == nil {
+		fm.Meta = make(map[string]any)
+	}
+
+	fm.Title = metaString(fm.Meta["title"])
+	fm.Author = metaString(fm.Meta["author"])
+	fm.Date = metaString(fm.Meta["date"])
+
+	// Tags are either a list, or a comma separated string.
+	switch tags := fm.Meta["tags"].(type) {
+	case []any:
+		for _, t := range tags {
+			fm.Tags = append(fm.Tags, metaString(t))
+		}
+	case string:
+		for _, t := range strings.Split(tags, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				fm.Tags = append(fm.Tags, t)
+			}
+		}
+	}
+}
+
+// metaString formats a front matter value as text.
+// Dates without a time of day are written as such.
+func metaString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 && v.Nanosecond() == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format(time.RFC3339)
+	}
+
+	return fmt.Sprint(v)
+}
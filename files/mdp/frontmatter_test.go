@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expFM    frontMatter
+		expBody  string
+		expError bool
+	}{
+		{name: "YAML",
+			input:   "---\ntitle: Hello\nauthor: Jane\ndate: 2022-05-16\ntags: [go, cli]\nteam: docs\n---\n# Body\n",
+			expFM:   frontMatter{Title: "Hello", Author: "Jane", Date: "2022-05-16", Tags: []string{"go", "cli"}},
+			expBody: "# Body\n"},
+		{name: "YAMLDots",
+			input:   "---\ntitle: Hello\n...\nBody\n",
+			expFM:   frontMatter{Title: "Hello"},
+			expBody: "Body\n"},
+		{name: "TOML",
+			input:   "+++\ntitle = \"Hello\"\ntags = \"go, cli\"\n+++\nBody\n",
+			expFM:   frontMatter{Title: "Hello", Tags: []string{"go", "cli"}},
+			expBody: "Body\n"},
+		{name: "CRLF",
+			input:   "---\r\ntitle: Hello\r\n---\r\nBody\r\n",
+			expFM:   frontMatter{Title: "Hello"},
+			expBody: "Body\r\n"},
+		{name: "None", input: "# Title\n\ntext\n", expBody: "# Title\n\ntext\n"},
+		{name: "Unclosed", input: "---\ntext\n", expBody: "---\ntext\n"},
+		{name: "Invalid", input: "---\ntitle: [\n---\nBody\n", expError: true},
+		{name: "Slides", input: "---\n# Slide 1\nHello world\n---\n# Slide 2\n",
+			expBody: "---\n# Slide 1\nHello world\n---\n# Slide 2\n"},
+		{name: "List", input: "---\n- one\n- two\n---\nBody\n", expBody: "---\n- one\n- two\n---\nBody\n"},
+		{name: "TOMLNotTable", input: "+++\n# Title\ntext\n+++\nBody\n", expBody: "+++\n# Title\ntext\n+++\nBody\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fm, body, err := splitFrontMatter([]byte(tc.input))
+			if tc.expError {
+				if err == nil {
+					t.Fatal("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(body) != tc.expBody {
+				t.Errorf("Expected body %q, got %q", tc.expBody, body)
+			}
+
+			fm.Meta = nil
+			if !reflect.DeepEqual(fm, tc.expFM) {
+				t.Errorf("Expected front matter %+v, got %+v", tc.expFM, fm)
+			}
+		})
+	}
+}
+
+func TestParseContent_FrontMatter(t *testing.T) {
+	tmpl := `<title>{{ .Title }}</title><p>{{ .Author }} {{ .Date }}` +
+		`{{ range .Tags }} #{{ . }}{{ end }} {{ .Meta.team }}</p>{{ .Body }}`
+	tFname := filepath.Join(t.TempDir(), "meta.html.tmpl")
+	if err := os.WriteFile(tFname, []byte(tmpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := "---\ntitle: Hello\nauthor: Jane\ndate: 2022-05-16\ntags: [go, cli]\nteam: docs\n---\n# Body\n"
+
+	result, err := parseContent([]byte(input), "test.md", tFname, renderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<title>Hello</title><p>Jane 2022-05-16 #go #cli docs</p><h1>Body</h1>\n"
+	if string(result) != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+	if strings.Contains(string(result), "author:") {
+		t.Errorf("Expected front matter to be stripped from the body")
+	}
+}
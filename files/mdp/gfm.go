@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kyokomi/emoji/v2"
+	"github.com/russross/blackfriday/v2"
+)
+
+// GitHub Flavored Markdown extensions, as named by the ext option.
+const (
+	extTaskList      = "tasklist"      // [ ] and [x] list items as checkboxes
+	extFootnote      = "footnote"      // [^1] references and their notes
+	extStrikethrough = "strikethrough" // ~~deleted~~ text
+	extAutolink      = "autolink"      // bare URLs as links
+	extAlert         = "alert"         // > [!NOTE] block quotes as alerts
+	extEmoji         = "emoji"         // :emoji: shortcodes as emoji
+)
+
+// extNames lists the extensions, in the order they're documented.
+var extNames = []string{extTaskList, extFootnote, extStrikethrough, extAutolink, extAlert, extEmoji}
+
+var (
+	// taskRe matches the checkbox starting a task list item.
+	taskRe = regexp.MustCompile(`^\[([ xX])\]\s+`)
+	// alertRe matches the type of alert starting a block quote, on its own line.
+	alertRe = regexp.MustCompile(`^\[!(?i:(note|tip|important|warning|caution))\][ \t]*(\n|$)`)
+	// alertClassRe matches the classes of the alert markup.
+	alertClassRe = regexp.MustCompile(`^markdown-alert( markdown-alert-[a-z]+)?$|^markdown-alert-title$`)
+	// footnoteIDRe matches the ids of footnotes and their references.
+	footnoteIDRe = regexp.MustCompile(`^fn(ref)?:[\pL\pN_.-]+$`)
+	// footnoteClassRe matches the classes of the footnote markup.
+	footnoteClassRe = regexp.MustCompile(`^footnote(s|-ref|-return)$`)
+	// shortcodeRe matches emoji shortcodes.
+	shortcodeRe = regexp.MustCompile(`:[a-zA-Z0-9_+-]+:`)
+)
+
+// extensions toggles the GitHub Flavored Markdown extensions.
+type extensions struct {
+	taskLists     bool // render task list items with checkboxes
+	footnotes     bool // render footnotes
+	strikethrough bool // render ~~text~~ as deleted
+	autolinks     bool // link bare URLs
+	alerts        bool // render > [!NOTE] block quotes as alerts
+	emoji         bool // replace :emoji: shortcodes
+}
+
+// allExtensions enables every extension.
+var allExtensions = extensions{
+	taskLists:     true,
+	footnotes:     true,
+	strikethrough: true,
+	autolinks:     true,
+	alerts:        true,
+	emoji:         true,
+}
+
+// parseExtensions returns the extensions enabled by a comma separated
+// list of their names, which can also be "all" or "none".
+func parseExtensions(list string) (extensions, error) {
+	var e extensions
+
+	for _, name := range strings.Split(list, ",") {
+		switch strings.TrimSpace(name) {
+		case "", "none":
+		case "all":
+			e = allExtensions
+		case extTaskList:
+			e.taskLists = true
+		case extFootnote:
+			e.footnotes = true
+		case extStrikethrough:
+			e.strikethrough = true
+		case extAutolink:
+			e.autolinks = true
+		case extAlert:
+			e.alerts = true
+		case extEmoji:
+			e.emoji = true
+		default:
+			return extensions{}, fmt.Errorf("%w: %s (available: %s, all, none)", ErrInvalidExtension, name,
+				strings.Join(extNames, ", "))
+		}
+	}
+
+	return e, nil
+}
+
+// parserFlags returns the blackfriday extensions to parse markdown with.
+func (e extensions) parserFlags() blackfriday.Extensions {
+	flags := blackfriday.CommonExtensions &^ (blackfriday.Strikethrough | blackfriday.Autolink)
+	if e.footnotes {
+		flags |= blackfriday.Footnotes
+	}
+	if e.strikethrough {
+		flags |= blackfriday.Strikethrough
+	}
+	if e.autolinks {
+		flags |= blackfriday.Autolink
+	}
+
+	return flags
+}
+
+// apply rewrites the parsed document for the extensions
+// blackfriday doesn't support itself.
+func (e extensions) apply(doc *blackfriday.Node) {
+	var quotes []*blackfriday.Node
+
+	doc.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if !entering {
+			return blackfriday.GoToNext
+		}
+
+		switch {
+		case node.Type == blackfriday.Item && e.taskLists:
+			taskItem(node)
+		case node.Type == blackfriday.BlockQuote && e.alerts:
+			quotes = append(quotes, node)
+		case node.Type == blackfriday.Text && e.emoji:
+			node.Literal = emojize(node.Literal)
+		}
+
+		return blackfriday.GoToNext
+	})
+
+	// Alerts replace their block quote, so they're
+	// only made once the walk is done.
+	for _, quote := range quotes {
+		alert(quote)
+	}
+}
+
+// taskItem adds a disabled checkbox to the list item if it
+// starts with [ ] or [x], removing the brackets.
+func taskItem(item *blackfriday.Node) {
+	text := item.FirstChild
+	if text != nil && text.Type == blackfriday.Paragraph {
+		text = text.FirstChild
+	}
+	if text == nil || text.Type != blackfriday.Text {
+		return
+	}
+
+	m := taskRe.FindSubmatch(text.Literal)
+	if m == nil {
+		return
+	}
+	text.Literal = text.Literal[len(m[0]):]
+
+	box := blackfriday.NewNode(blackfriday.HTMLSpan)
+	box.Literal = []byte(`<input type="checkbox" disabled="">`)
+	if m[1][0] != ' ' {
+		box.Literal = []byte(`<input type="checkbox" checked="" disabled="">`)
+	}
+	text.InsertBefore(box)
+
+	space := blackfriday.NewNode(blackfriday.Text)
+	space.Literal = []byte(" ")
+	text.InsertBefore(space)
+}
+
+// alert replaces the block quote with an alert if its first
+// line is an alert type, like [!NOTE].
+func alert(quote *blackfriday.Node) {
+	para := quote.FirstChild
+	if para == nil || para.Type != blackfriday.Paragraph {
+		return
+	}
+	text := para.FirstChild
+	if text == nil || text.Type != blackfriday.Text {
+		return
+	}
+
+	m := alertRe.FindSubmatch(text.Literal)
+	if m == nil {
+		return
+	}
+	kind := strings.ToLower(string(m[1]))
+
+	// Remove the alert type, and its line if that leaves it empty.
+	text.Literal = text.Literal[len(m[0]):]
+	if len(text.Literal) == 0 {
+		next := text.Next
+		text.Unlink()
+		if next != nil && (next.Type == blackfriday.Softbreak || next.Type == blackfriday.Hardbreak) {
+			next.Unlink()
+		}
+	}
+	if para.FirstChild == nil {
+		para.Unlink()
+	}
+
+	open := blackfriday.NewNode(blackfriday.HTMLBlock)
+	open.Literal = []byte(fmt.Sprintf("<div class=\"markdown-alert markdown-alert-%s\">\n"+
+		"<p class=\"markdown-alert-title\">%s</p>", kind, strings.ToUpper(kind[:1])+kind[1:]))
+	quote.InsertBefore(open)
+
+	for child := quote.FirstChild; child != nil; {
+		next := child.Next
+		child.Unlink()
+		quote.InsertBefore(child)
+		child = next
+	}
+
+	end := blackfriday.NewNode(blackfriday.HTMLBlock)
+	end.Literal = []byte("</div>")
+	quote.InsertBefore(end)
+
+	quote.Unlink()
+}
+
+// emojize replaces the known emoji shortcodes in text.
+func emojize(text []byte) []byte {
+	codes := emoji.CodeMap()
+
+	return shortcodeRe.ReplaceAllFunc(text, func(code []byte) []byte {
+		if e, ok := codes[string(code)]; ok {
+			return []byte(e)
+		}
+		return code
+	})
+}
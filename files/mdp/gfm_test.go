@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExtensions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ext      string
+		input    string
+		expect   []string
+		unexpect []string
+	}{
+		{name: "TaskList", ext: extTaskList,
+			input: "- [ ] todo\n- [x] done\n- [a] not a task\n",
+			expect: []string{`<li><input type="checkbox" disabled=""> todo</li>`,
+				`<li><input type="checkbox" checked="" disabled=""> done</li>`, "<li>[a] not a task</li>"}},
+		{name: "TaskListDisabled", ext: "none",
+			input: "- [ ] todo\n", expect: []string{"<li>[ ] todo</li>"}, unexpect: []string{"<input"}},
+		{name: "Footnote", ext: extFootnote,
+			input: "Text[^1].\n\n[^1]: The note.\n",
+			expect: []string{`<sup class="footnote-ref" id="fnref:1"><a href="#fn:1"`,
+				`<div class="footnotes">`, `<li id="fn:1">The note. <a class="footnote-return" href="#fnref:1"`}},
+		{name: "FootnoteDisabled", ext: "none",
+			input: "Text[^1].\n\n[^1]: The note.\n", unexpect: []string{"<sup", "footnotes"}},
+		{name: "Strikethrough", ext: extStrikethrough,
+			input: "~~gone~~\n", expect: []string{"<del>gone</del>"}},
+		{name: "StrikethroughDisabled", ext: "none",
+			input: "~~gone~~\n", expect: []string{"~~gone~~"}},
+		{name: "Autolink", ext: extAutolink,
+			input: "See https://go.dev.\n", expect: []string{`<a href="https://go.dev"`}},
+		{name: "AutolinkDisabled", ext: "none",
+			input: "See https://go.dev.\n", unexpect: []string{"<a "}},
+		{name: "Alert", ext: extAlert,
+			input: "> [!WARNING]\n> Mind the *gap*.\n",
+			expect: []string{"<div class=\"markdown-alert markdown-alert-warning\">\n<p class=\"markdown-alert-title\">Warning</p>",
+				"<p>Mind the <em>gap</em>.</p>\n\n</div>"}},
+		{name: "AlertLowercase", ext: extAlert,
+			input: "> [!tip]\n> Try it.\n", expect: []string{`<div class="markdown-alert markdown-alert-tip">`}},
+		{name: "AlertPlainQuote", ext: extAlert,
+			input: "> [!NOTE] inline\n", expect: []string{"<blockquote>"}, unexpect: []string{"markdown-alert"}},
+		{name: "AlertDisabled", ext: "none",
+			input: "> [!NOTE]\n> Info.\n", expect: []string{"<blockquote>"}, unexpect: []string{"markdown-alert"}},
+		{name: "Emoji", ext: extEmoji,
+			input:  "Ship it :rocket: :+1: :not_an_emoji: `:rocket:`\n",
+			expect: []string{"Ship it 🚀 👍 :not_an_emoji: <code>:rocket:</code>"}},
+		{name: "EmojiDisabled", ext: "none",
+			input: "Ship it :rocket:\n", expect: []string{"Ship it :rocket:"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exts, err := parseExtensions(tc.ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, policy := range []string{policyStrict, policyUGC} {
+				result, err := parseContent([]byte(tc.input), "", "", renderOptions{policy: policy, extensions: exts})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for _, exp := range tc.expect {
+					if !strings.Contains(string(result), exp) {
+						t.Errorf("Policy %q: expected %q, got:\n%s", policy, exp, result)
+					}
+				}
+				for _, unexp := range tc.unexpect {
+					if strings.Contains(string(result), unexp) {
+						t.Errorf("Policy %q: expected no %q, got:\n%s", policy, unexp, result)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParseExtensions(t *testing.T) {
+	testCases := []struct {
+		name   string
+		list   string
+		expect extensions
+		expErr error
+	}{
+		{name: "All", list: "all", expect: allExtensions},
+		{name: "None", list: "none"},
+		{name: "Some", list: "tasklist, emoji", expect: extensions{taskLists: true, emoji: true}},
+		{name: "Invalid", list: "tasklist,tables", expErr: ErrInvalidExtension},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exts, err := parseExtensions(tc.list)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Fatalf("Expected error %q, got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exts != tc.expect {
+				t.Errorf("Expected %+v, got %+v", tc.expect, exts)
+			}
+		})
+	}
+}
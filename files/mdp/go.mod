@@ -3,12 +3,22 @@ module github.com/adamwoolhether/cliApps/files/mdp
 go 1.18
 
 require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/alecthomas/chroma/v2 v2.3.0
+	github.com/go-pdf/fpdf v0.6.0
+	github.com/kyokomi/emoji/v2 v2.2.13
 	github.com/microcosm-cc/bluemonday v1.0.18
 	github.com/russross/blackfriday/v2 v2.1.0
+	golang.org/x/image v0.5.0
+	golang.org/x/term v0.5.0
+	golang.org/x/text v0.7.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/aymerick/douceur v0.2.0 // indirect
+	github.com/dlclark/regexp2 v1.4.0 // indirect
 	github.com/gorilla/css v1.0.0 // indirect
-	golang.org/x/net v0.0.0-20210614182718-04defd469f4e // indirect
+	golang.org/x/net v0.0.0-20220722155237-a158d28d115b // indirect
+	golang.org/x/sys v0.5.0 // indirect
 )
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/russross/blackfriday/v2"
+)
+
+// defaultStyle is the color theme of highlighted code blocks.
+const defaultStyle = "github"
+
+// noStyle disables highlighting.
+const noStyle = "none"
+
+// highlightClassRe matches the classes of the highlighting markup.
+var highlightClassRe = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// highlightRenderer is a blackfriday renderer highlighting fenced code
+// blocks with a language tag, writing the other nodes like HTMLRenderer.
+type highlightRenderer struct {
+	*blackfriday.HTMLRenderer
+	style       *chroma.Style
+	formatter   *chromahtml.Formatter
+	highlighted bool // some code block was highlighted
+}
+
+// newHighlightRenderer returns a renderer highlighting code with the named style.
+func newHighlightRenderer(r *blackfriday.HTMLRenderer, style string) (*highlightRenderer, error) {
+	s, err := chromaStyle(style)
+	if err != nil {
+		return nil, err
+	}
+
+	return &highlightRenderer{
+		HTMLRenderer: r,
+		style:        s,
+		formatter:    chromahtml.New(chromahtml.WithClasses(true)),
+	}, nil
+}
+
+// chromaStyle returns the named highlighting style.
+func chromaStyle(name string) (*chroma.Style, error) {
+	s, ok := styles.Registry[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s (available: %s)", ErrInvalidStyle, name, strings.Join(styles.Names(), ", "))
+	}
+
+	return s, nil
+}
+
+// RenderNode writes the node, highlighting code blocks
+// whose language is known to chroma.
+func (r *highlightRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+	if node.Type != blackfriday.CodeBlock {
+		return r.HTMLRenderer.RenderNode(w, node, entering)
+	}
+
+	// The language is the first word of the info string.
+	lang := strings.Fields(string(node.Info))
+	if len(lang) == 0 {
+		return r.HTMLRenderer.RenderNode(w, node, entering)
+	}
+
+	lexer := lexers.Get(lang[0])
+	if lexer == nil {
+		return r.HTMLRenderer.RenderNode(w, node, entering)
+	}
+
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, string(node.Literal))
+	if err != nil {
+		return r.HTMLRenderer.RenderNode(w, node, entering)
+	}
+
+	var buf bytes.Buffer
+	if err := r.formatter.Format(&buf, r.style, it); err != nil {
+		return r.HTMLRenderer.RenderNode(w, node, entering)
+	}
+	r.highlighted = true
+
+	// Write the markup as raw HTML, spaced like other blocks.
+	block := blackfriday.NewNode(blackfriday.HTMLBlock)
+	block.Literal = buf.Bytes()
+	return r.HTMLRenderer.RenderNode(w, block, entering)
+}
+
+// css returns the stylesheet of the highlighting markup,
+// or nil if no code block was highlighted.
+func (r *highlightRenderer) css() ([]byte, error) {
+	if !r.highlighted {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	if err := r.formatter.WriteCSS(&buf, r.style); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
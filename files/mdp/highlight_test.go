@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseContent_Highlight(t *testing.T) {
+	input := "```go\nfunc main() {}\n```\n\n```nosuchlang\nx < y\n```\n"
+
+	testCases := []struct {
+		name     string
+		style    string
+		expect   []string
+		unexpect []string
+	}{
+		{name: "Style", style: "monokai",
+			expect: []string{
+				`<pre class="chroma"><code><span class="line"><span class="cl"><span class="kd">func</span>`,
+				".chroma .kd { color: #66d9ef }",
+				"<pre><code>x &lt; y\n</code></pre>",
+			}},
+		{name: "Disabled", style: "",
+			expect:   []string{"<pre><code>func main() {}\n</code></pre>"},
+			unexpect: []string{"chroma", "<style>"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := parseContent([]byte(input), "", "", renderOptions{style: tc.style})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, exp := range tc.expect {
+				if !strings.Contains(string(result), exp) {
+					t.Errorf("Expected result to contain %q, got:\n%s", exp, result)
+				}
+			}
+			for _, unexp := range tc.unexpect {
+				if strings.Contains(string(result), unexp) {
+					t.Errorf("Expected result without %q, got:\n%s", unexp, result)
+				}
+			}
+		})
+	}
+}
+
+func TestParseContent_NoHighlightedCode(t *testing.T) {
+	result, err := parseContent([]byte("# Title\n"), "", "", renderOptions{style: defaultStyle})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(result), "<style>") {
+		t.Errorf("Expected no stylesheet without highlighted code, got:\n%s", result)
+	}
+}
+
+func TestParseContent_InvalidStyle(t *testing.T) {
+	_, err := parseContent([]byte("# Title\n"), "", "", renderOptions{style: "nosuchstyle"})
+	if !errors.Is(err, ErrInvalidStyle) {
+		t.Errorf("Expected error %q, got %q", ErrInvalidStyle, err)
+	}
+}
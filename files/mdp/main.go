@@ -7,13 +7,24 @@ import (
 	"html/template"
 	"io"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
-	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/microcosm-cc/bluemonday"
 	"github.com/russross/blackfriday/v2"
+	"golang.org/x/term"
+)
+
+// Output formats.
+const (
+	formatHTML       = "html"       // HTML page
+	formatStandalone = "standalone" // HTML page with its images and stylesheets inlined
+	formatPDF        = "pdf"        // PDF document
+	formatSlides     = "slides"     // HTML slide deck, with its images inlined
+	formatTerminal   = "terminal"   // ANSI styled text, paged in the terminal
 )
 
 const defaultTemplate = `<!DOCTYPE html>
@@ -21,28 +32,150 @@ const defaultTemplate = `<!DOCTYPE html>
   <head>
     <meta http-equiv="content-type" content="text/html; charset=utf-8">
     <title>{{ .Title }}</title>
-  </head>
+  {{ with .CSS }}<style>
+{{ . }}</style>
+  {{ end }}</head>
   <body>
-{{ .Body }}
-  </body>
+{{ with .TOC }}<nav class="toc">
+{{ . }}</nav>
+{{ end }}{{ .Body }}
+{{ with .Scripts }}{{ . }}{{ end }}  </body>
 </html>
 `
 
 // content represents the HTML content to add into the template.
+// Title, Author, Date and Tags come from the front matter of the
+// markdown file, and Meta holds all of its keys. TOC is only set
+// with the toc option, CSS when code blocks were highlighted or math
+// rendered, and Scripts when diagrams or math are rendered.
 type content struct {
-	Title string
-	File  string
-	Body  template.HTML
+	Title   string
+	File    string
+	Body    template.HTML
+	TOC     template.HTML
+	CSS     template.CSS
+	Scripts template.HTML
+	Author  string
+	Date    string
+	Tags    []string
+	Meta    map[string]any
+}
+
+// renderOptions holds the options changing how markdown is rendered.
+type renderOptions struct {
+	toc        bool       // add ids to headings and a table of contents
+	tocDepth   int        // deepest heading level listed in the table of contents
+	style      string     // highlighting style of code blocks, empty to disable it
+	policy     string     // sanitization policy preset, ugc if empty
+	policyFile string     // YAML file with elements and attributes to allow
+	extensions extensions // GitHub Flavored Markdown extensions to enable
+	mermaid    bool       // render mermaid code blocks as diagrams
+	math       bool       // render $ and $$ delimited TeX math
+	theme      string     // built-in theme, the default template if empty
+	slides     bool       // split the document into slides on --- lines
+	width      int        // width of the terminal output, in columns
+	color      bool       // style the terminal output with ANSI escape sequences
+
+	deterministic bool      // reproducible output, byte for byte
+	date          time.Time // date of the deterministic output, such as the PDF's
 }
 
 func main() {
 	// Parse flags
 	filename := flag.String("file", "", "Markdown file to preview")
 	skipPreview := flag.Bool("s", false, "Skip auto-preview")
-	tFname := flag.String("t", "", "Alternate template name")
+	openCmd := flag.String("open", "", "Command opening the preview (default the OS's, such as xdg-open)")
+	tFname := flag.String("t", os.Getenv("TEMPLATE_FILENAME"), "Alternate template file, or template directory with layouts and partials (default $TEMPLATE_FILENAME)")
+	theme := flag.String("theme", "", "Built-in theme: dark, light, print or slides")
+	toc := flag.Bool("toc", false, "Add heading anchors and a table of contents")
+	tocDepth := flag.Int("toc-depth", defaultTOCDepth, "Deepest heading level in the table of contents")
+	style := flag.String("style", defaultStyle, "Highlighting style of fenced code blocks, or \""+noStyle+"\" to disable it")
+	policy := flag.String("policy", policyUGC, "Sanitization policy: strict, ugc, trusted or none")
+	policyFile := flag.String("policy-file", "", "YAML file with elements and attributes to allow on top of the policy")
+	ext := flag.String("ext", "all", "Comma separated GitHub Flavored Markdown extensions: "+strings.Join(extNames, ", ")+", all or none")
+	mermaid := flag.Bool("mermaid", false, "Render mermaid code blocks as diagrams")
+	math := flag.Bool("math", false, "Render $ and $$ delimited TeX math")
 	stdin := flag.Bool("stdin", false, "Read from stdin")
+	serveFile := flag.Bool("serve", false, "Serve a live-reloading preview over HTTP")
+	addr := flag.String("addr", "localhost:8080", "Address to serve the preview on with -serve")
+	dir := flag.String("dir", "", "Directory of markdown files to render into a static site")
+	outName := flag.String("o", "", "Output file, or output directory of the site rendered with -dir (default a temp file, or \""+defaultSiteDir+"\" with -dir)")
+	checkMode := flag.Bool("check", false, "Check the -file or the markdown files under -dir for broken links, missing images and heading problems")
+	deterministic := flag.Bool("deterministic", false, "Make the output reproducible, dating it from $SOURCE_DATE_EPOCH, else the Unix epoch")
+	format := flag.String("format", formatHTML, "Output format: html, standalone (HTML with images and stylesheets inlined), pdf, slides or terminal (the default without a display)")
 	flag.Parse()
 
+	opts := renderOptions{
+		toc:        *toc,
+		tocDepth:   *tocDepth,
+		style:      *style,
+		policy:     *policy,
+		policyFile: *policyFile,
+		mermaid:    *mermaid,
+		math:       *math,
+		theme:      *theme,
+		width:      terminalWidth(os.Stdout),
+		color:      *outName == "" && term.IsTerminal(int(os.Stdout.Fd())),
+	}
+	if opts.style == noStyle {
+		opts.style = ""
+	}
+	exts, err := parseExtensions(*ext)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts.extensions = exts
+
+	if *deterministic {
+		if opts.date, err = sourceDate(os.Getenv("SOURCE_DATE_EPOCH")); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		opts.deterministic = true
+	}
+
+	// Without a display to open the preview on, such as in SSH
+	// sessions, the file is previewed in the terminal instead.
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["format"] && !set["o"] && !set["open"] && !*skipPreview && !*serveFile && !hasDisplay() {
+		*format = formatTerminal
+	}
+
+	var open opener
+	if !*skipPreview && *dir == "" && !*checkMode && *format != formatTerminal {
+		kept := *outName != "" || *serveFile
+		if open, err = previewOpener(*openCmd, hasDisplay(), kept, os.Stderr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if *checkMode {
+		name := *filename
+		if *dir != "" {
+			name = *dir
+		}
+		if name == "" {
+			flag.Usage()
+			os.Exit(1)
+		}
+		if err := checkFiles([]string{name}, opts, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *dir != "" {
+		if err := buildSite(*dir, *outName, *tFname, opts, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if !*stdin {
 		// If no file input provided, show usage.
 		if *filename == "" {
@@ -51,15 +184,37 @@ func main() {
 		}
 	}
 
-	if err := run(*filename, *tFname, os.Stdout, *skipPreview, *stdin); err != nil {
+	if *serveFile {
+		if *stdin {
+			fmt.Fprintln(os.Stderr, "-serve can't preview stdin")
+			os.Exit(1)
+		}
+		if err := serve(*filename, *tFname, opts, *addr, os.Stdout, open); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := run(*filename, *tFname, *outName, *format, opts, os.Stdout, open, *stdin); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
-// run coordinates te execution of the program's functions.
-func run(filename, tFname string, out io.Writer, skipPreview, stdin bool) (err error) {
+// run coordinates te execution of the program's functions. The
+// output is written to outName, or to a temp file if it's empty,
+// and previewed with open unless it's nil. A temp file is kept
+// until an interrupt or termination signal, and then removed.
+func run(filename, tFname, outName, format string, opts renderOptions, out io.Writer, open opener, stdin bool) (err error) {
+	switch format {
+	case formatHTML, formatStandalone, formatPDF, formatSlides, formatTerminal:
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidFormat, format)
+	}
+
 	var input []byte
+	baseDir := "."
 
 	if !stdin {
 		// Read data from the input file and check for errors
@@ -67,6 +222,7 @@ func run(filename, tFname string, out io.Writer, skipPreview, stdin bool) (err e
 		if err != nil {
 			return err
 		}
+		baseDir = filepath.Dir(filename)
 	} else {
 		input, err = io.ReadAll(os.Stdin)
 		if err != nil {
@@ -75,65 +231,168 @@ func run(filename, tFname string, out io.Writer, skipPreview, stdin bool) (err e
 		filename = "stdin"
 	}
 
-	htmlData, err := parseContent(input, filename, tFname)
+	data, err := export(input, filename, tFname, baseDir, format, opts)
 	if err != nil {
 		return err
 	}
 
-	// Create a temp file and check for errors
-	temp, err := os.CreateTemp("", "mdp*.html")
-	if err != nil {
-		return err
+	// Terminal output is previewed right away.
+	if format == formatTerminal && outName == "" {
+		return writePaged(data, out)
 	}
-	if err = temp.Close(); err != nil {
-		return err
+
+	temp := outName == ""
+	preview := temp && open != nil
+
+	// Catch signals before the temp file exists, so it's always removed.
+	sig := make(chan os.Signal, 1)
+	if preview {
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sig)
+	}
+
+	if temp {
+		// Create a temp file and check for errors
+		f, err := os.CreateTemp("", "mdp*."+extension(format))
+		if err != nil {
+			return err
+		}
+		if err = f.Close(); err != nil {
+			return err
+		}
+		outName = f.Name()
+
+		if preview {
+			defer os.Remove(outName)
+		}
 	}
-	outName := temp.Name()
 
 	fmt.Fprintln(out, outName)
 
-	if err = saveHTML(outName, htmlData); err != nil {
+	if err = saveFile(outName, data); err != nil {
 		return err
 	}
 
-	if skipPreview {
+	if open == nil {
 		return nil
 	}
 
-	defer os.Remove(outName)
+	if err = open(outName); err != nil || !temp {
+		return err
+	}
+
+	fmt.Fprintln(out, "Previewing, press Ctrl+C to exit and remove the file")
+	<-sig
 
-	return preview(outName)
+	return nil
 }
 
-func parseContent(input []byte, srcFileName, tFname string) ([]byte, error) {
-	// Parse the markdown file through blackfriday and
-	// bluemonday to generate a valid and safe HTML file
-	output := blackfriday.Run(input)
-	body := bluemonday.UGCPolicy().SanitizeBytes(output)
+// export renders the markdown input in the given format. Local images
+// and stylesheets are read relative to baseDir.
+func export(input []byte, filename, tFname, baseDir, format string, opts renderOptions) ([]byte, error) {
+	if format == formatPDF {
+		var buf bytes.Buffer
+		if err := renderPDF(input, baseDir, opts, &buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
 
-	// Parse content of the defaultTemplate const into a new Template
-	t, err := template.New("mdp").Parse(defaultTemplate)
+	if format == formatTerminal {
+		var buf bytes.Buffer
+		if err := renderTerminal(input, opts, &buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	if format == formatSlides {
+		opts.slides = true
+		if opts.theme == "" {
+			opts.theme = slidesTheme
+		}
+	}
+
+	htmlData, err := parseContent(input, filename, tFname, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// If user provides an alternate template, replace default.
-	// Use can also use env var to set filename.
-	if f := os.Getenv("TEMPLATE_FILENAME"); f != "" {
-		tFname = os.Getenv("TEMPLATE_FILENAME")
+	if format == formatStandalone || format == formatSlides {
+		return inlineAssets(htmlData, baseDir)
 	}
-	if tFname != "" {
-		t, err = template.ParseFiles(tFname)
-		if err != nil {
-			return nil, err
-		}
+
+	return htmlData, nil
+}
+
+// extension returns the file extension of the format.
+func extension(format string) string {
+	switch format {
+	case formatPDF:
+		return "pdf"
+	case formatTerminal:
+		return "txt"
+	}
+
+	return "html"
+}
+
+// parseContent renders the markdown input into an HTML page with the
+// tFname template, or the theme or default template. The page depends
+// on the arguments only, so the same input always renders the same page.
+func parseContent(input []byte, srcFileName, tFname string, opts renderOptions) ([]byte, error) {
+	// Strip the front matter, if any, from the markdown.
+	fm, input, err := splitFrontMatter(input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Slides are marked before parsing, and have no table of contents.
+	if opts.slides {
+		input, opts.toc = markSlides(input), false
+	}
+
+	// Parse the markdown file through blackfriday and
+	// bluemonday to generate a valid and safe HTML file
+	output, toc, css, scripts, err := renderMarkdown(input, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.slides {
+		output = deckSections(output)
+	}
+	policy, err := newPolicy(opts)
+	if err != nil {
+		return nil, err
+	}
+	body := output
+	if policy != nil {
+		body = policy.SanitizeBytes(output)
+	}
+
+	// Use the alternate template if the user provides one, else the
+	// theme or the defaultTemplate const. The front matter can pick
+	// the layout of a template directory.
+	t, err := loadTemplate(tFname, opts.theme, metaString(fm.Meta["layout"]))
+	if err != nil {
+		return nil, err
 	}
 
 	// Instantiate the content type, adding the title and body.
 	c := content{
-		Title: "Markdown Preview Tool",
-		File:  filepath.Base(srcFileName),
-		Body:  template.HTML(body),
+		Title:   "Markdown Preview Tool",
+		File:    filepath.Base(srcFileName),
+		Body:    template.HTML(body),
+		TOC:     template.HTML(toc),
+		CSS:     template.CSS(css),
+		Scripts: template.HTML(scripts),
+		Author:  fm.Author,
+		Date:    fm.Date,
+		Tags:    fm.Tags,
+		Meta:    fm.Meta,
+	}
+	if fm.Title != "" {
+		c.Title = fm.Title
 	}
 
 	// Create a buffer of bytes to write to file
@@ -147,42 +406,92 @@ func parseContent(input []byte, srcFileName, tFname string) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
-func saveHTML(outFname string, data []byte) error {
-	// Write the bytes to the file.
-	return os.WriteFile(outFname, data, 0644)
+// renderMarkdown renders input into HTML with blackfriday and the
+// extensions in opts, returning the table of contents too if opts.toc
+// is set, the stylesheet of the code blocks highlighted with opts.style
+// and of the math, and the scripts rendering diagrams and math.
+func renderMarkdown(input []byte, opts renderOptions) (output, toc, css, scripts []byte, err error) {
+	params := blackfriday.HTMLRendererParameters{
+		Flags: blackfriday.CommonHTMLFlags,
+	}
+	if opts.extensions.footnotes {
+		params.Flags |= blackfriday.FootnoteReturnLinks
+		params.FootnoteReturnLinkContents = "↩"
+	}
+	html := blackfriday.NewHTMLRenderer(params)
+
+	var r blackfriday.Renderer = html
+	var hr *highlightRenderer
+	if opts.style != "" {
+		if hr, err = newHighlightRenderer(html, opts.style); err != nil {
+			return nil, nil, nil, nil, err
+		}
+		r = hr
+	}
+
+	doc, spans := parseMarkdown(input, opts)
+	mermaid, math := setDiagrams(doc, opts)
+
+	if opts.toc {
+		toc = renderTOC(setHeadingIDs(doc, opts.tocDepth))
+	}
+
+	var buf bytes.Buffer
+	r.RenderHeader(&buf, doc)
+	doc.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		return r.RenderNode(&buf, node, entering)
+	})
+	r.RenderFooter(&buf, doc)
+
+	if hr != nil {
+		if css, err = hr.css(); err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+
+	output = buf.Bytes()
+	if len(spans) > 0 {
+		output, toc, math = restoreMath(output, spans), restoreMath(toc, spans), true
+	}
+
+	scripts, mathCSS, err := renderers(mermaid, math)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	return output, toc, append(css, mathCSS...), scripts, nil
 }
 
-func preview(fname string) error {
-	cName := ""
-	cParams := []string{}
-
-	// Define executable based on OS
-	switch runtime.GOOS {
-	case "linux":
-		cName = "xdg-open"
-	case "windows":
-		cName = "cmd.exe"
-	case "darwin":
-		cName = "open"
-	default:
-		return fmt.Errorf("os not supported")
+// parseMarkdown parses input with the extensions in opts applied. With
+// opts.math, the math is replaced by placeholders, returned in spans.
+func parseMarkdown(input []byte, opts renderOptions) (doc *blackfriday.Node, spans []mathSpan) {
+	if opts.math {
+		input, spans = protectMath(input)
 	}
 
-	// Append filename to parameters slice
-	cParams = append(cParams, fname)
+	md := blackfriday.New(blackfriday.WithExtensions(opts.extensions.parserFlags()))
+	doc = md.Parse(input)
+	opts.extensions.apply(doc)
 
-	// Locate executable in path
-	cPath, err := exec.LookPath(cName)
+	return doc, spans
+}
+
+// sourceDate returns the date of deterministic output: the epoch,
+// in seconds, of SOURCE_DATE_EPOCH if set, else the Unix epoch.
+func sourceDate(epoch string) (time.Time, error) {
+	if epoch == "" {
+		return time.Unix(0, 0).UTC(), nil
+	}
+
+	sec, err := strconv.ParseInt(epoch, 10, 64)
 	if err != nil {
-		return err
+		return time.Time{}, fmt.Errorf("%w: %s", ErrInvalidDate, epoch)
 	}
 
-	// Open the file using default executable
-	exec.Command(cPath, cParams...).Run()
+	return time.Unix(sec, 0).UTC(), nil
+}
 
-	// Give the browser time to open the file before deleting it.
-	// This is a temporary solution. We should implement handling
-	// and OS signal in the future.
-	time.Sleep(2 * time.Second)
-	return err
+func saveFile(outFname string, data []byte) error {
+	// Write the bytes to the file.
+	return os.WriteFile(outFname, data, 0644)
 }
@@ -2,9 +2,12 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"flag"
 	"os"
-	"strings"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 const (
@@ -12,18 +15,23 @@ const (
 	goldenFile = "./testdata/test1.md.html"
 )
 
-func TestParseContent(t *testing.T) {
-	input, err := os.ReadFile(inputFile)
-	if err != nil {
-		t.Fatal(err)
-	}
+// update regenerates the golden files from the results, to review
+// template changes with git diff: go test -run TestParseContent -update
+var update = flag.Bool("update", false, "Update the golden files with the results")
 
-	result, err := parseContent(input, "", "")
-	if err != nil {
-		t.Fatal(err)
+// checkGolden compares the result with the golden file, or
+// writes the result to the golden file with -update.
+func checkGolden(t *testing.T, golden string, result []byte) {
+	t.Helper()
+
+	if *update {
+		if err := os.WriteFile(golden, result, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
 	}
 
-	expected, err := os.ReadFile(goldenFile)
+	expected, err := os.ReadFile(golden)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -31,35 +39,89 @@ func TestParseContent(t *testing.T) {
 	if !bytes.Equal(expected, result) {
 		t.Logf("golden:\n%s\n", expected)
 		t.Logf("result:\n%s\n", result)
-		t.Errorf("Result content doesn't match golden file.")
+		t.Errorf("Result content doesn't match golden file %s, run go test -update to regenerate it.", golden)
+	}
+}
+
+func TestParseContent(t *testing.T) {
+	input, err := os.ReadFile(inputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name   string
+		opts   renderOptions
+		golden string
+	}{
+		{name: "Default", golden: goldenFile},
+		{name: "Light", opts: renderOptions{theme: "light"}, golden: "./testdata/test1.md.light.html"},
+		{name: "Dark", opts: renderOptions{theme: "dark"}, golden: "./testdata/test1.md.dark.html"},
+		{name: "Print", opts: renderOptions{theme: "print"}, golden: "./testdata/test1.md.print.html"},
+		{name: "Slides", opts: renderOptions{theme: slidesTheme, slides: true}, golden: "./testdata/test1.md.slides.html"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := parseContent(input, inputFile, "", tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkGolden(t, tc.golden, result)
+		})
 	}
 }
 
 func TestRun(t *testing.T) {
 	var mockStdout bytes.Buffer
+	outName := filepath.Join(t.TempDir(), "out.html")
 
-	if err := run(inputFile, "", &mockStdout, true, false); err != nil {
+	if err := run(inputFile, "", outName, formatHTML, renderOptions{}, &mockStdout, nil, false); err != nil {
 		t.Fatal(err)
 	}
 
-	resultFile := strings.TrimSpace(mockStdout.String())
-
-	result, err := os.ReadFile(resultFile)
-	if err != nil {
-		t.Fatal(err)
+	if mockStdout.String() != outName+"\n" {
+		t.Errorf("Expected %q, got %q", outName+"\n", mockStdout.String())
 	}
 
-	expected, err := os.ReadFile(goldenFile)
+	result, err := os.ReadFile(outName)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !bytes.Equal(expected, result) {
-		t.Logf("golden:\n%s\n", expected)
-		t.Logf("result:\n%s\n", result)
-		t.Errorf("Result content doesn't match golden file.")
+
+	checkGolden(t, goldenFile, result)
+}
+
+func TestSourceDate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		epoch  string
+		expect time.Time
+		expErr error
+	}{
+		{name: "Unset", expect: time.Unix(0, 0).UTC()},
+		{name: "Epoch", epoch: "1700000000", expect: time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)},
+		{name: "Invalid", epoch: "yesterday", expErr: ErrInvalidDate},
 	}
 
-	os.Remove(resultFile)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := sourceDate(tc.epoch)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Fatalf("Expected error %q, got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Equal(tc.expect) {
+				t.Errorf("Expected %s, got %s", tc.expect, result)
+			}
+		})
+	}
 }
 
 const customTemplate = "template-filename.html.tmpl"
@@ -71,7 +133,7 @@ func TestParseContent_CustomTemplate(t *testing.T) {
 	}
 
 	// Should probably make this test more robust, rather than just checking if the function works.
-	_, err = parseContent(input, "", customTemplate)
+	_, err = parseContent(input, "", customTemplate, renderOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/russross/blackfriday/v2"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// PDF layout, in millimeters and points.
+const (
+	pdfMargin     = 20.0 // page margins, in mm
+	pdfIndent     = 6.0  // indentation of lists and quotes, in mm
+	pdfFontSize   = 11.0 // body text size, in pt
+	pdfCodeSize   = 9.0  // code size, in pt
+	pdfLineFactor = 1.4  // line height, relative to the font size
+	ptToMM        = 25.4 / 72
+)
+
+// pdfFamily is the family the bundled UTF-8 fonts are registered as.
+const pdfFamily = "DejaVu"
+
+// pdfFonts holds the DejaVu Sans Condensed fonts, by style.
+//
+//go:embed fonts
+var pdfFonts embed.FS
+
+// pdfFontFiles holds the font styles and their files in pdfFonts,
+// in the order they're added to documents.
+var pdfFontFiles = [...]struct{ style, fname string }{
+	{"", "fonts/DejaVuSansCondensed.ttf"},
+	{"B", "fonts/DejaVuSansCondensed-Bold.ttf"},
+	{"I", "fonts/DejaVuSansCondensed-Oblique.ttf"},
+	{"BI", "fonts/DejaVuSansCondensed-BoldOblique.ttf"},
+}
+
+// maxUnsupported is how many unsupported characters an error lists.
+const maxUnsupported = 5
+
+// headingSizes holds the font size of each heading level, in pt.
+var headingSizes = [...]float64{1: 20, 2: 16, 3: 14, 4: 12, 5: 11, 6: 11}
+
+// pdfWriter renders the nodes of a markdown document into a PDF. Text
+// uses the bundled UTF-8 fonts, code the Courier core font when it only
+// holds Windows-1252 characters.
+type pdfWriter struct {
+	pdf     *fpdf.Fpdf
+	tr      func(string) string // converts UTF-8 text to the core fonts encoding
+	baseDir string              // directory relative image paths are resolved from
+
+	size   float64 // current font size, in pt
+	bold   int     // nesting of strong text
+	italic int     // nesting of emphasized text
+	strike int     // nesting of deleted text
+	core   bool    // writing code in the Courier core font
+	link   string  // destination of the link being written
+	lists  []int   // number of the next item of each open list, 0 for bullets
+	quotes int     // nesting of block quotes
+}
+
+// renderPDF renders the markdown input into a PDF written to out. Images
+// are read relative to baseDir. Front matter sets the document title
+// and author. Deterministic output is dated opts.date rather than now.
+func renderPDF(input []byte, baseDir string, opts renderOptions, out io.Writer) error {
+	fm, input, err := splitFrontMatter(input)
+	if err != nil {
+		return err
+	}
+
+	md := blackfriday.New(blackfriday.WithExtensions(blackfriday.CommonExtensions))
+	doc := md.Parse(input)
+
+	if err := checkGlyphs(doc); err != nil {
+		return err
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	for _, f := range pdfFontFiles {
+		data, err := pdfFonts.ReadFile(f.fname)
+		if err != nil {
+			return err
+		}
+		pdf.AddUTF8FontFromBytes(pdfFamily, f.style, data)
+	}
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+	if fm.Title != "" {
+		pdf.SetTitle(fm.Title, true)
+	}
+	if fm.Author != "" {
+		pdf.SetAuthor(fm.Author, true)
+	}
+	if opts.deterministic {
+		pdf.SetCreationDate(opts.date)
+		pdf.SetModificationDate(opts.date)
+		pdf.SetCatalogSort(true)
+	}
+	pdf.AddPage()
+
+	w := &pdfWriter{
+		pdf:     pdf,
+		tr:      pdf.UnicodeTranslatorFromDescriptor(""),
+		baseDir: baseDir,
+		size:    pdfFontSize,
+	}
+	w.setFont()
+
+	doc.Walk(w.node)
+
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+
+	return pdf.Output(out)
+}
+
+// lineHeight returns the height of a line of the current font, in mm.
+func (w *pdfWriter) lineHeight() float64 {
+	return w.size * ptToMM * pdfLineFactor
+}
+
+// checkGlyphs returns an error listing the characters of the document
+// the bundled fonts have no glyph for, rather than dropping them.
+func checkGlyphs(doc *blackfriday.Node) error {
+	data, err := pdfFonts.ReadFile(pdfFontFiles[0].fname)
+	if err != nil {
+		return err
+	}
+	font, err := sfnt.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	var buf sfnt.Buffer
+	seen := make(map[rune]bool)
+	var missing []string
+
+	doc.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if node.Type != blackfriday.Text && node.Type != blackfriday.Code && node.Type != blackfriday.CodeBlock {
+			return blackfriday.GoToNext
+		}
+		for _, r := range string(node.Literal) {
+			if seen[r] || unicode.IsControl(r) || unicode.Is(unicode.Variation_Selector, r) {
+				continue
+			}
+			seen[r] = true
+			if i, err := font.GlyphIndex(&buf, r); err != nil || i == 0 {
+				missing = append(missing, fmt.Sprintf("%q (%U)", r, r))
+			}
+		}
+		return blackfriday.GoToNext
+	})
+
+	if len(missing) == 0 {
+		return nil
+	}
+	if len(missing) > maxUnsupported {
+		missing = append(missing[:maxUnsupported], fmt.Sprintf("and %d more", len(missing)-maxUnsupported))
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedText, strings.Join(missing, ", "))
+}
+
+// isCodePage reports whether text only holds Windows-1252 characters,
+// which the core fonts can write.
+func isCodePage(text string) bool {
+	_, err := charmap.Windows1252.NewEncoder().String(text)
+	return err == nil
+}
+
+// setFont applies the current font settings.
+func (w *pdfWriter) setFont() {
+	family, style := pdfFamily, ""
+	if w.core {
+		family = "Courier"
+	}
+	if w.bold > 0 {
+		style += "B"
+	}
+	if w.italic > 0 {
+		style += "I"
+	}
+	if w.strike > 0 {
+		style += "S"
+	}
+
+	w.pdf.SetFont(family, style, w.size)
+
+	if w.link != "" {
+		w.pdf.SetTextColor(0, 0, 200)
+	} else if w.quotes > 0 {
+		w.pdf.SetTextColor(100, 100, 100)
+	} else {
+		w.pdf.SetTextColor(0, 0, 0)
+	}
+}
+
+// setIndent sets the left margin for the open lists and quotes.
+func (w *pdfWriter) setIndent() {
+	w.pdf.SetLeftMargin(pdfMargin + pdfIndent*float64(len(w.lists)+w.quotes))
+}
+
+// endBlock ends the current line, leaving space after a block
+// unless it's inside a list item.
+func (w *pdfWriter) endBlock(node *blackfriday.Node) {
+	w.pdf.Ln(w.lineHeight())
+	if node.Parent == nil || node.Parent.Type != blackfriday.Item {
+		w.pdf.Ln(w.lineHeight() / 2)
+	}
+}
+
+// setCode switches to or from code, in the Courier core font when
+// text can be written in it.
+func (w *pdfWriter) setCode(code bool, text string) {
+	w.core, w.size = code && isCodePage(text), pdfFontSize
+	if code {
+		w.size = pdfCodeSize
+	}
+	w.setFont()
+}
+
+// encode converts text to the encoding of the current font.
+func (w *pdfWriter) encode(text string) string {
+	if w.core {
+		return w.tr(text)
+	}
+	return text
+}
+
+// write writes text in the current font, as a link if inside one.
+func (w *pdfWriter) write(text string) {
+	text = w.encode(text)
+	if w.link != "" {
+		w.pdf.WriteLinkString(w.lineHeight(), text, w.link)
+		return
+	}
+	w.pdf.Write(w.lineHeight(), text)
+}
+
+// node renders a node of the document, as a blackfriday.NodeVisitor.
+func (w *pdfWriter) node(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+	switch node.Type {
+	case blackfriday.Heading:
+		if entering {
+			w.size, w.bold = headingSizes[node.Level], w.bold+1
+		} else {
+			w.endBlock(node)
+			w.size, w.bold = pdfFontSize, w.bold-1
+		}
+		w.setFont()
+	case blackfriday.Paragraph:
+		if !entering {
+			w.endBlock(node)
+		}
+	case blackfriday.Text:
+		w.write(string(node.Literal))
+	case blackfriday.Softbreak:
+		w.write(" ")
+	case blackfriday.Hardbreak:
+		w.pdf.Ln(w.lineHeight())
+	case blackfriday.Emph:
+		w.italic += step(entering)
+		w.setFont()
+	case blackfriday.Strong:
+		w.bold += step(entering)
+		w.setFont()
+	case blackfriday.Del:
+		w.strike += step(entering)
+		w.setFont()
+	case blackfriday.Link:
+		w.link = ""
+		if entering {
+			w.link = string(node.LinkData.Destination)
+		}
+		w.setFont()
+	case blackfriday.Code:
+		w.setCode(true, string(node.Literal))
+		w.write(string(node.Literal))
+		w.setCode(false, "")
+	case blackfriday.CodeBlock:
+		w.codeBlock(node)
+	case blackfriday.Image:
+		w.image(node)
+		return blackfriday.SkipChildren
+	case blackfriday.List:
+		if entering {
+			start := 0
+			if node.ListFlags&blackfriday.ListTypeOrdered != 0 {
+				start = 1
+			}
+			w.lists = append(w.lists, start)
+		} else {
+			w.lists = w.lists[:len(w.lists)-1]
+			if node.Parent.Type != blackfriday.Item {
+				w.pdf.Ln(w.lineHeight() / 2)
+			}
+		}
+		w.setIndent()
+	case blackfriday.Item:
+		if entering {
+			w.item()
+		}
+	case blackfriday.BlockQuote:
+		w.quotes += step(entering)
+		w.setIndent()
+		w.setFont()
+	case blackfriday.HorizontalRule:
+		left, _, right, _ := w.pdf.GetMargins()
+		width, _ := w.pdf.GetPageSize()
+		y := w.pdf.GetY() + w.lineHeight()/2
+		w.pdf.Line(left, y, width-right, y)
+		w.pdf.Ln(w.lineHeight())
+	case blackfriday.Table:
+		w.table(node)
+		return blackfriday.SkipChildren
+	}
+
+	return blackfriday.GoToNext
+}
+
+// step returns how much a nesting counter changes when entering
+// or leaving a node.
+func step(entering bool) int {
+	if entering {
+		return 1
+	}
+	return -1
+}
+
+// item writes the bullet or number of a list item, hanging in the indentation.
+func (w *pdfWriter) item() {
+	n := &w.lists[len(w.lists)-1]
+
+	mark := "•"
+	if *n > 0 {
+		mark = strconv.Itoa(*n) + "."
+		*n++
+	}
+
+	left, _, _, _ := w.pdf.GetMargins()
+	w.pdf.SetX(left - pdfIndent)
+	w.pdf.CellFormat(pdfIndent, w.lineHeight(), mark, "", 0, "L", false, 0, "")
+}
+
+// codeBlock writes a code block on a shaded background.
+func (w *pdfWriter) codeBlock(node *blackfriday.Node) {
+	text := strings.TrimSuffix(string(node.Literal), "\n")
+	w.setCode(true, text)
+
+	w.pdf.SetFillColor(240, 240, 240)
+	w.pdf.MultiCell(0, w.lineHeight(), w.encode(text), "", "L", true)
+
+	w.setCode(false, "")
+	w.pdf.Ln(w.lineHeight() / 2)
+}
+
+// image draws a local PNG, JPEG or GIF image, scaled down to the page
+// width. Other images, including remote ones, are written as their alt text.
+func (w *pdfWriter) image(node *blackfriday.Node) {
+	dest := string(node.LinkData.Destination)
+	fname := filepath.Join(w.baseDir, filepath.FromSlash(dest))
+
+	imgType := strings.TrimPrefix(strings.ToLower(filepath.Ext(fname)), ".")
+	if imgType == "jpeg" {
+		imgType = "jpg"
+	}
+
+	if _, err := os.Stat(fname); err != nil || !isRelative(dest) ||
+		(imgType != "png" && imgType != "jpg" && imgType != "gif") {
+		w.write(plainText(node))
+		return
+	}
+
+	opts := fpdf.ImageOptions{ImageType: imgType, ReadDpi: true}
+	info := w.pdf.RegisterImageOptions(fname, opts)
+	if info == nil {
+		return
+	}
+
+	left, _, right, _ := w.pdf.GetMargins()
+	pageWidth, _ := w.pdf.GetPageSize()
+
+	width, height := info.Extent()
+	if maxWidth := pageWidth - left - right; width > maxWidth {
+		width, height = maxWidth, height*maxWidth/width
+	}
+
+	// Images start on their own line.
+	if w.pdf.GetX() > left {
+		w.pdf.Ln(w.lineHeight())
+	}
+	w.pdf.ImageOptions(fname, left, -1, width, height, true, opts, 0, "")
+}
+
+// table writes a table with equal width columns.
+func (w *pdfWriter) table(node *blackfriday.Node) {
+	var rows [][]string
+	var header int
+
+	node.Walk(func(n *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		switch {
+		case entering && n.Type == blackfriday.TableRow:
+			rows = append(rows, nil)
+		case entering && n.Type == blackfriday.TableCell:
+			rows[len(rows)-1] = append(rows[len(rows)-1], plainText(n))
+			if n.IsHeader && len(rows) == 1 {
+				header = 1
+			}
+			return blackfriday.SkipChildren
+		}
+		return blackfriday.GoToNext
+	})
+
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return
+	}
+
+	left, _, right, _ := w.pdf.GetMargins()
+	pageWidth, _ := w.pdf.GetPageSize()
+	width := (pageWidth - left - right) / float64(len(rows[0]))
+
+	for i, row := range rows {
+		if i < header {
+			w.bold++
+			w.setFont()
+		}
+		for _, cell := range row {
+			w.pdf.CellFormat(width, w.lineHeight(), cell, "1", 0, "L", false, 0, "")
+		}
+		w.pdf.Ln(-1)
+		if i < header {
+			w.bold--
+			w.setFont()
+		}
+	}
+
+	w.pdf.Ln(w.lineHeight() / 2)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"gopkg.in/yaml.v3"
+)
+
+// Sanitization policy presets.
+const (
+	policyStrict  = "strict"  // basic formatting, links and nothing else
+	policyUGC     = "ugc"     // bluemonday's policy for user generated content
+	policyTrusted = "trusted" // ugc plus iframes, classes, ids and details
+	policyNone    = "none"    // no sanitization at all
+)
+
+// policyFile describes the elements and attributes allowed
+// on top of a preset, as read from a YAML file.
+type policyFile struct {
+	Base       string              `yaml:"base"`        // preset to extend
+	Elements   []string            `yaml:"elements"`    // elements allowed without attributes
+	Attributes map[string][]string `yaml:"attributes"`  // attributes and the elements allowing them, all if empty
+	URLSchemes []string            `yaml:"url_schemes"` // URL schemes allowed in links
+}
+
+// idRe matches the ids and classes allowed by the trusted preset.
+var idRe = regexp.MustCompile(`^[\pL\pN_ -]+$`)
+
+// newPolicy returns the policy sanitizing the rendered HTML, as set by the
+// preset and policy file in opts, or nil if the HTML isn't sanitized.
+// With the toc option, the heading ids the table of contents links to are
+// kept, and with a highlighting style, the classes of the highlighting
+// markup. So is the markup of the enabled markdown extensions, diagrams,
+// math and slides.
+func newPolicy(opts renderOptions) (*bluemonday.Policy, error) {
+	preset := opts.policy
+	if preset == "" {
+		preset = policyUGC
+	}
+
+	var pf policyFile
+	if opts.policyFile != "" {
+		data, err := os.ReadFile(opts.policyFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &pf); err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrInvalidPolicy, opts.policyFile, err)
+		}
+		if pf.Base != "" {
+			preset = pf.Base
+		}
+	}
+
+	p, err := presetPolicy(preset)
+	if err != nil || p == nil {
+		return nil, err
+	}
+
+	if len(pf.Elements) > 0 {
+		p.AllowElements(pf.Elements...)
+	}
+	for attr, elements := range pf.Attributes {
+		if len(elements) == 0 {
+			p.AllowAttrs(attr).Globally()
+			continue
+		}
+		p.AllowAttrs(attr).OnElements(elements...)
+	}
+	if len(pf.URLSchemes) > 0 {
+		p.AllowURLSchemes(pf.URLSchemes...)
+	}
+
+	if opts.toc {
+		p.AllowAttrs("id").Matching(headingIDRe).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	}
+	if opts.style != "" {
+		p.AllowAttrs("class").Matching(highlightClassRe).OnElements("pre", "span")
+	}
+	if opts.extensions.taskLists {
+		p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+		p.AllowAttrs("checked", "disabled").OnElements("input")
+	}
+	if opts.extensions.footnotes {
+		p.AllowAttrs("id").Matching(footnoteIDRe).OnElements("sup", "li")
+		p.AllowAttrs("class").Matching(footnoteClassRe).OnElements("sup", "div", "a")
+		p.AllowAttrs("href").OnElements("a")
+	}
+	if opts.extensions.alerts {
+		p.AllowAttrs("class").Matching(alertClassRe).OnElements("div", "p")
+	}
+	if opts.mermaid || opts.math {
+		p.AllowAttrs("class").Matching(diagramClassRe).OnElements("pre", "span", "div")
+	}
+	if opts.slides {
+		p.AllowAttrs("class").Matching(slideClassRe).OnElements("section", "aside")
+	}
+
+	return p, nil
+}
+
+// presetPolicy returns the named policy preset,
+// which is nil for the one not sanitizing.
+func presetPolicy(name string) (*bluemonday.Policy, error) {
+	switch name {
+	case policyStrict:
+		p := bluemonday.NewPolicy()
+		p.AllowStandardURLs()
+		p.AllowAttrs("href").OnElements("a")
+		p.AllowElements("p", "br", "hr", "h1", "h2", "h3", "h4", "h5", "h6",
+			"ul", "ol", "li", "blockquote", "pre", "code", "em", "strong", "del",
+			"table", "thead", "tbody", "tr", "th", "td")
+		return p, nil
+	case policyUGC:
+		return bluemonday.UGCPolicy(), nil
+	case policyTrusted:
+		p := bluemonday.UGCPolicy()
+		p.AllowElements("details", "summary")
+		p.AllowAttrs("open").OnElements("details")
+		p.AllowAttrs("class", "id").Matching(idRe).Globally()
+		p.AllowAttrs("src", "width", "height", "title", "allow", "allowfullscreen", "frameborder").
+			OnElements("iframe")
+		return p, nil
+	case policyNone:
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s (available: %s)", ErrInvalidPolicy, name,
+		strings.Join([]string{policyStrict, policyUGC, policyTrusted, policyNone}, ", "))
+}
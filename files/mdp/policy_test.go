@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// xssPayloads are markdown inputs trying to run scripts in the preview.
+var xssPayloads = []string{
+	"<script>alert(1)</script>",
+	`<img src="x" onerror="alert(1)">`,
+	"[click](javascript:alert(1))",
+	`<a href="javascript:alert(1)">click</a>`,
+	`<iframe src="javascript:alert(1)"></iframe>`,
+	`<svg onload="alert(1)"></svg>`,
+	`<div style="background:url(javascript:alert(1))">x</div>`,
+	`<details open ontoggle="alert(1)"><summary>x</summary></details>`,
+	`<p class="x" onclick="alert(1)">x</p>`,
+}
+
+func TestPolicyBlocksXSS(t *testing.T) {
+	for _, policy := range []string{"", policyStrict, policyUGC, policyTrusted} {
+		for _, payload := range xssPayloads {
+			result, err := parseContent([]byte(payload+"\n"), "", "", renderOptions{policy: policy})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, unexp := range []string{"<script", "javascript:", "onerror", "onload", "onclick", "ontoggle"} {
+				if strings.Contains(string(result), unexp) {
+					t.Errorf("Policy %q: expected %q to be stripped from %q, got:\n%s", policy, unexp, payload, result)
+				}
+			}
+		}
+	}
+}
+
+func TestPolicyPresets(t *testing.T) {
+	input := "<details><summary>More</summary>Hidden</details>\n\n" +
+		`<iframe src="https://example.com/diagram" width="400"></iframe>` + "\n\n" +
+		`<p class="note">Note</p>` + "\n\n![img](img.png)\n"
+
+	testCases := []struct {
+		policy   string
+		expect   []string
+		unexpect []string
+	}{
+		{policy: policyStrict,
+			expect:   []string{"Note"},
+			unexpect: []string{"<details>", "<iframe", `class="note"`, "<img"}},
+		{policy: policyUGC,
+			expect:   []string{"<details>", `<img src="img.png"`},
+			unexpect: []string{"<iframe", `class="note"`}},
+		{policy: policyTrusted,
+			expect: []string{"<details><summary>More</summary>", `<iframe src="https://example.com/diagram" width="400">`,
+				`<p class="note">`}},
+		{policy: policyNone,
+			expect: []string{"<details>", "<iframe", `class="note"`}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.policy, func(t *testing.T) {
+			result, err := parseContent([]byte(input), "", "", renderOptions{policy: tc.policy})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, exp := range tc.expect {
+				if !strings.Contains(string(result), exp) {
+					t.Errorf("Expected result to contain %q, got:\n%s", exp, result)
+				}
+			}
+			for _, unexp := range tc.unexpect {
+				if strings.Contains(string(result), unexp) {
+					t.Errorf("Expected result without %q, got:\n%s", unexp, result)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyFile(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "policy.yaml")
+	policy := "base: strict\nelements: [mark]\nattributes:\n  class: [mark]\n  title: []\n"
+	if err := os.WriteFile(policyFile, []byte(policy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := `<mark class="hl" title="t">marked</mark> <em class="x" title="e">em</em> <script>alert(1)</script>` + "\n"
+
+	result, err := parseContent([]byte(input), "", "", renderOptions{policyFile: policyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `<p><mark class="hl" title="t">marked</mark> <em title="e">em</em> </p>`
+	if !strings.Contains(string(result), exp) {
+		t.Errorf("Expected result to contain %q, got:\n%s", exp, result)
+	}
+}
+
+func TestPolicyInvalid(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(policyFile, []byte("elements: [\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name string
+		opts renderOptions
+	}{
+		{name: "Preset", opts: renderOptions{policy: "lax"}},
+		{name: "File", opts: renderOptions{policyFile: policyFile}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseContent([]byte("text\n"), "", "", tc.opts)
+			if !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("Expected error %q, got %q", ErrInvalidPolicy, err)
+			}
+		})
+	}
+}
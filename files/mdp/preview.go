@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// opener opens a file or URL for previewing, usually in a browser.
+type opener func(target string) error
+
+// newOpener returns an opener running the command, split into words,
+// with the target as its last argument. An empty command is the
+// default one of the OS.
+func newOpener(command string) (opener, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		switch runtime.GOOS {
+		case "linux", "freebsd", "openbsd", "netbsd":
+			args = []string{"xdg-open"}
+		case "darwin":
+			args = []string{"open"}
+		case "windows":
+			// The empty argument is the title of the window start opens.
+			args = []string{"cmd.exe", "/C", "start", ""}
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedOS, runtime.GOOS)
+		}
+	}
+
+	// Locate the executable in PATH
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return func(target string) error {
+		return exec.Command(path, append(args[1:len(args):len(args)], target)...).Run()
+	}, nil
+}
+
+// previewOpener returns the opener of previews running command, or nil
+// if no command is given and there's no display to open them on. When
+// the output outlives the preview, in an output file or a server, a
+// missing command only gets a warning, written to errOut.
+func previewOpener(command string, display, kept bool, errOut io.Writer) (opener, error) {
+	if command == "" && !display {
+		return nil, nil
+	}
+
+	open, err := newOpener(command)
+	if err != nil && kept {
+		fmt.Fprintf(errOut, "Not previewing: %s\n", err)
+		return nil, nil
+	}
+
+	return open, err
+}
+
+// hasDisplay reports whether previews can be opened on a graphical
+// display, which remote sessions, such as SSH ones, usually lack.
+func hasDisplay() bool {
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		return os.Getenv("SSH_CONNECTION") == ""
+	}
+
+	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
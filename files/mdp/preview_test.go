@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunPreview(t *testing.T) {
+	errOpen := errors.New("no browser")
+
+	testCases := []struct {
+		name      string
+		outName   string
+		openErr   error
+		interrupt bool // the opener sends an interrupt, as the user would
+		expKept   bool
+	}{
+		{name: "TempUntilInterrupt", interrupt: true},
+		{name: "TempOpenError", openErr: errOpen},
+		{name: "OutputFile", outName: "out.html", expKept: true},
+		{name: "OutputFileOpenError", outName: "out.html", openErr: errOpen, expKept: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			outName := tc.outName
+			if outName != "" {
+				outName = filepath.Join(t.TempDir(), outName)
+			}
+
+			var opened string
+			open := func(target string) error {
+				opened = target
+				if _, err := os.Stat(target); err != nil {
+					t.Errorf("Expected the preview to exist when opened: %s", err)
+				}
+				if tc.interrupt {
+					p, err := os.FindProcess(os.Getpid())
+					if err != nil {
+						return err
+					}
+					return p.Signal(os.Interrupt)
+				}
+				return tc.openErr
+			}
+
+			var out bytes.Buffer
+			err := run(inputFile, "", outName, formatHTML, renderOptions{}, &out, open, false)
+			if !errors.Is(err, tc.openErr) {
+				t.Fatalf("Expected error %v, got %v", tc.openErr, err)
+			}
+
+			fname := strings.SplitN(out.String(), "\n", 2)[0]
+			if opened != fname {
+				t.Errorf("Expected %q to be opened, got %q", fname, opened)
+			}
+
+			_, err = os.Stat(fname)
+			if tc.expKept && err != nil {
+				t.Errorf("Expected %s to be kept: %s", fname, err)
+			}
+			if !tc.expKept && !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Expected %s to be removed, got %v", fname, err)
+			}
+		})
+	}
+}
+
+func TestNewOpener(t *testing.T) {
+	open, err := newOpener("touch")
+	if err != nil {
+		t.Skip("touch is not available:", err)
+	}
+
+	target := filepath.Join(t.TempDir(), "opened")
+	if err := open(target); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(target); err != nil {
+		t.Errorf("Expected the command to run with the target: %s", err)
+	}
+
+	if _, err := newOpener("mdp-no-such-command"); err == nil {
+		t.Error("Expected an error for a missing command")
+	}
+}
+
+func TestPreviewOpener(t *testing.T) {
+	testCases := []struct {
+		name    string
+		command string
+		display bool
+		kept    bool
+		expOpen bool
+		expErr  bool
+		expWarn bool
+	}{
+		{name: "NoDisplay"},
+		{name: "NoDisplayMissingCommand", command: "mdp-no-such-command", expErr: true},
+		{name: "Command", command: "touch", expOpen: true},
+		{name: "MissingCommand", command: "mdp-no-such-command", display: true, expErr: true},
+		{name: "MissingCommandKept", command: "mdp-no-such-command", display: true, kept: true, expWarn: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.expOpen {
+				if _, err := newOpener(tc.command); err != nil {
+					t.Skip(tc.command, "is not available:", err)
+				}
+			}
+
+			var errOut bytes.Buffer
+			open, err := previewOpener(tc.command, tc.display, tc.kept, &errOut)
+			if (err != nil) != tc.expErr {
+				t.Fatalf("Expected error %t, got %v", tc.expErr, err)
+			}
+			if (open != nil) != tc.expOpen {
+				t.Errorf("Expected an opener %t, got %t", tc.expOpen, open != nil)
+			}
+			if (errOut.Len() > 0) != tc.expWarn {
+				t.Errorf("Expected a warning %t, got %q", tc.expWarn, errOut.String())
+			}
+		})
+	}
+}
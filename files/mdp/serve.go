@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// pollInterval is how often the served files are checked for changes.
+var pollInterval = 250 * time.Millisecond
+
+// reloadScript is added to served pages, reloading
+// them when the server sends a reload event.
+const reloadScript = `<script>
+  new EventSource("/events").onmessage = function() { location.reload(); };
+</script>
+`
+
+// fileState is what's compared to tell if a watched file changed.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// server serves the preview of a markdown file, rendering it again
+// and telling the browsers to reload it whenever it or its template change.
+type server struct {
+	filename string               // markdown file to preview
+	tFname   string               // alternate template name
+	opts     renderOptions        // rendering options
+	states   map[string]fileState // state of the watched files
+	files    http.Handler         // serves the files next to the markdown file
+
+	mu      sync.Mutex
+	page    []byte                 // last rendered page
+	clients map[chan struct{}]bool // browsers waiting for reload events
+
+	done chan struct{} // closed when the server stops
+}
+
+// newServer returns a server for the given markdown file,
+// rendering it for the first time.
+func newServer(filename, tFname string, opts renderOptions) (*server, error) {
+	s := &server{
+		filename: filename,
+		tFname:   tFname,
+		opts:     opts,
+		files:    http.FileServer(http.Dir(filepath.Dir(filename))),
+		clients:  make(map[chan struct{}]bool),
+		done:     make(chan struct{}),
+	}
+
+	s.states = s.stat()
+	if err := s.render(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// serve previews the markdown file on a local HTTP server listening on
+// addr, opened with open unless it's nil, reloading it in the browser
+// when it changes, until it receives an interrupt or termination signal.
+func serve(filename, tFname string, opts renderOptions, addr string, out io.Writer, open opener) error {
+	s, err := newServer(filename, tFname, opts)
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	url := "http://" + l.Addr().String()
+	fmt.Fprintf(out, "Serving %s at %s\n", filename, url)
+
+	errCh := make(chan error, 2)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	go func() {
+		if err := srv.Serve(l); err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+
+	go s.watch(pollInterval, out)
+
+	if open != nil {
+		go func() {
+			if err := open(url); err != nil {
+				errCh <- err
+			}
+		}()
+	}
+
+	select {
+	case <-sig:
+	case err = <-errCh:
+	}
+
+	// Event streams never go idle, so they're closed before shutting down.
+	s.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if sErr := srv.Shutdown(ctx); err == nil {
+		err = sErr
+	}
+
+	return err
+}
+
+// ServeHTTP serves the rendered page on /, the reload events on /events
+// and the files next to the markdown file, such as its images, on the
+// other paths.
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/":
+		s.mu.Lock()
+		page := s.page
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(page)
+	case "/events":
+		s.events(w, r)
+	default:
+		s.files.ServeHTTP(w, r)
+	}
+}
+
+// events streams a server-sent event to the browser every time
+// the page is rendered again.
+func (s *server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	reload := make(chan struct{}, 1)
+
+	s.mu.Lock()
+	s.clients[reload] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, reload)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-reload:
+			if _, err := io.WriteString(w, "data: reload\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// watch checks the markdown file and its template for changes every
+// interval until the server is closed. Changed files are rendered again
+// and the browsers told to reload. Errors are written to out, keeping
+// the last page.
+func (s *server) watch(interval time.Duration, out io.Writer) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.done:
+			return
+		}
+
+		states := s.stat()
+		if statesEqual(states, s.states) {
+			continue
+		}
+		s.states = states
+
+		if err := s.render(); err != nil {
+			fmt.Fprintln(out, err)
+			continue
+		}
+		s.notify()
+	}
+}
+
+// stat returns the state of the markdown file and its template, or
+// of every file under the template directory, with its layouts and
+// partials. Files that can't be read are left out, so they count as
+// changed once they can be read again.
+func (s *server) stat() map[string]fileState {
+	states := make(map[string]fileState)
+
+	for _, fname := range []string{s.filename, s.tFname} {
+		if fname == "" {
+			continue
+		}
+		filepath.WalkDir(fname, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			states[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		})
+	}
+
+	return states
+}
+
+func statesEqual(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || !v.modTime.Equal(w.modTime) || v.size != w.size {
+			return false
+		}
+	}
+
+	return true
+}
+
+// render parses the markdown file into the page,
+// adding the script that reloads it.
+func (s *server) render() error {
+	input, err := os.ReadFile(s.filename)
+	if err != nil {
+		return err
+	}
+
+	htmlData, err := parseContent(input, s.filename, s.tFname, s.opts)
+	if err != nil {
+		return err
+	}
+
+	// Add the script at the end of the body, or
+	// at the end of the page if there's no body tag.
+	if i := bytes.LastIndex(htmlData, []byte("</body>")); i >= 0 {
+		// Keep the indentation of the closing tag.
+		if j := bytes.LastIndexByte(htmlData[:i], '\n') + 1; len(bytes.TrimSpace(htmlData[j:i])) == 0 {
+			i = j
+		}
+		htmlData = append(htmlData[:i:i], append([]byte(reloadScript), htmlData[i:]...)...)
+	} else {
+		htmlData = append(htmlData, reloadScript...)
+	}
+
+	s.mu.Lock()
+	s.page = htmlData
+	s.mu.Unlock()
+
+	return nil
+}
+
+// notify tells every connected browser to reload the page.
+func (s *server) notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for reload := range s.clients {
+		// A pending reload is enough, don't block on slow clients.
+		select {
+		case reload <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// close stops watching files and ends the event streams.
+func (s *server) close() {
+	close(s.done)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getPage(t *testing.T, url string) string {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body)
+}
+
+func TestServe(t *testing.T) {
+	input, err := os.ReadFile(inputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fname := filepath.Join(t.TempDir(), "test.md")
+	if err := os.WriteFile(fname, input, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := newServer(fname, "", renderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	page := getPage(t, ts.URL)
+	for _, exp := range []string{"<h1>Test Markdown File</h1>", reloadScript + "  </body>"} {
+		if !strings.Contains(page, exp) {
+			t.Errorf("Expected page to contain %q, got:\n%s", exp, page)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected content type %q, got %q", "text/event-stream", ct)
+	}
+
+	go s.watch(10*time.Millisecond, io.Discard)
+
+	if err := os.WriteFile(fname, []byte("# Changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the change is seen even with a coarse modification time.
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(fname, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan string)
+	go func() {
+		line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		events <- line
+	}()
+
+	select {
+	case line := <-events:
+		if line != "data: reload\n" {
+			t.Fatalf("Expected reload event, got %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for reload event")
+	}
+
+	if page := getPage(t, ts.URL); !strings.Contains(page, "<h1>Changed</h1>") {
+		t.Errorf("Expected changed page, got:\n%s", page)
+	}
+}
+
+func TestServeFiles(t *testing.T) {
+	dir := createSite(t, map[string]string{
+		"doc.md":      "![img](img/pic.png)\n",
+		"img/pic.png": "png",
+	})
+
+	s, err := newServer(filepath.Join(dir, "doc.md"), "", renderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	if page := getPage(t, ts.URL+"/img/pic.png"); page != "png" {
+		t.Errorf("Expected the image next to the file, got %q", page)
+	}
+}
+
+func TestServeNotFound(t *testing.T) {
+	s, err := newServer(inputFile, "", renderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestServerStatTemplateDir(t *testing.T) {
+	dir := createSite(t, map[string]string{
+		"doc.md":                         "# Doc\n",
+		"tmpl/layouts/default.html.tmpl": `{{ template "partials/nav.html.tmpl" . }}{{ .Body }}`,
+		"tmpl/partials/nav.html.tmpl":    "<nav></nav>",
+	})
+	s := &server{filename: filepath.Join(dir, "doc.md"), tFname: filepath.Join(dir, "tmpl")}
+
+	states := s.stat()
+	for _, fname := range []string{"doc.md", "tmpl/layouts/default.html.tmpl", "tmpl/partials/nav.html.tmpl"} {
+		if _, ok := states[filepath.Join(dir, filepath.FromSlash(fname))]; !ok {
+			t.Errorf("Expected the state of %s, got %v", fname, states)
+		}
+	}
+
+	partial := filepath.Join(dir, "tmpl", "partials", "nav.html.tmpl")
+	if err := os.WriteFile(partial, []byte("<nav>Changed</nav>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if statesEqual(states, s.stat()) {
+		t.Error("Expected a change to a partial to change the states")
+	}
+}
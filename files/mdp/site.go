@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultSiteDir is where the site is written if no output directory is given.
+const defaultSiteDir = "site"
+
+// hrefRe matches the link targets of the rendered HTML.
+var hrefRe = regexp.MustCompile(`href="([^"]*)"`)
+
+// page is a markdown file rendered into the site.
+type page struct {
+	title string // front matter title, first heading of the file, or its name
+	path  string // slash separated path of the HTML file, relative to the site
+}
+
+// buildSite renders every markdown file under dir into outDir with the
+// given template, keeping the directory layout and rewriting links between
+// markdown files to the rendered pages. Other files are copied as they
+// are, and an index page linking to every page is generated unless dir
+// has its own index.md.
+func buildSite(dir, outDir, tFname string, opts renderOptions, out io.Writer) error {
+	if outDir == "" {
+		outDir = defaultSiteDir
+	}
+
+	// The output directory is skipped if it's inside dir.
+	absOut, err := filepath.Abs(outDir)
+	if err != nil {
+		return err
+	}
+
+	var pages []page
+	hasIndex := false
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if abs, err := filepath.Abs(p); err == nil && abs == absOut {
+				return filepath.SkipDir
+			}
+			if p != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		if !isMarkdown(p) {
+			return copyFile(p, filepath.Join(outDir, rel))
+		}
+
+		pg, err := renderPage(p, rel, outDir, tFname, opts)
+		if err != nil {
+			return err
+		}
+		if pg.path == "index.html" {
+			hasIndex = true
+		}
+		pages = append(pages, pg)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !hasIndex {
+		if err := writeIndex(pages, outDir, tFname, opts); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(out, "Rendered %d pages into %s\n", len(pages), outDir)
+	return err
+}
+
+// isMarkdown reports whether fname is a markdown file, by its extension.
+func isMarkdown(fname string) bool {
+	ext := strings.ToLower(filepath.Ext(fname))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// htmlName returns the name of the page rendered from the markdown file fname.
+func htmlName(fname string) string {
+	return strings.TrimSuffix(fname, path.Ext(fname)) + ".html"
+}
+
+// renderPage renders the markdown file fname, found at rel in the
+// source directory, into the same place under outDir.
+func renderPage(fname, rel, outDir, tFname string, opts renderOptions) (page, error) {
+	input, err := os.ReadFile(fname)
+	if err != nil {
+		return page{}, err
+	}
+
+	htmlData, err := parseContent(input, fname, tFname, opts)
+	if err != nil {
+		return page{}, err
+	}
+
+	// The front matter title takes precedence over the first heading.
+	fm, body, err := splitFrontMatter(input)
+	if err != nil {
+		return page{}, err
+	}
+
+	pg := page{
+		title: fm.Title,
+		path:  htmlName(filepath.ToSlash(rel)),
+	}
+	if pg.title == "" {
+		pg.title = markdownTitle(body, filepath.Base(fname))
+	}
+
+	outName := filepath.Join(outDir, filepath.FromSlash(pg.path))
+	if err := os.MkdirAll(filepath.Dir(outName), 0755); err != nil {
+		return page{}, err
+	}
+
+	return pg, saveFile(outName, rewriteLinks(htmlData))
+}
+
+// rewriteLinks points the relative links to markdown
+// files in htmlData to their rendered pages.
+func rewriteLinks(htmlData []byte) []byte {
+	return hrefRe.ReplaceAllFunc(htmlData, func(m []byte) []byte {
+		link := string(hrefRe.FindSubmatch(m)[1])
+
+		// Keep any query or fragment as it is.
+		target, rest := link, ""
+		if i := strings.IndexAny(link, "?#"); i >= 0 {
+			target, rest = link[:i], link[i:]
+		}
+
+		if !isRelative(target) || !isMarkdown(target) {
+			return m
+		}
+
+		return []byte(`href="` + htmlName(target) + rest + `"`)
+	})
+}
+
+// isRelative reports whether the link target is a path
+// relative to the current page, without scheme or host.
+func isRelative(target string) bool {
+	if target == "" || strings.HasPrefix(target, "/") {
+		return false
+	}
+
+	// A colon before any slash starts a scheme, such as https: or mailto:.
+	if i := strings.IndexByte(target, ':'); i >= 0 && !strings.Contains(target[:i], "/") {
+		return false
+	}
+
+	return true
+}
+
+// markdownTitle returns the text of the first level one
+// heading of the markdown input, or def if it has none.
+func markdownTitle(input []byte, def string) string {
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "# ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "# "))
+		}
+	}
+
+	return def
+}
+
+// writeIndex renders an index page linking to every page.
+func writeIndex(pages []page, outDir, tFname string, opts renderOptions) error {
+	var md bytes.Buffer
+	md.WriteString("# Index\n\n")
+
+	for _, pg := range pages {
+		// Escape the link, which may hold spaces, and the title brackets.
+		link := (&url.URL{Path: pg.path}).String()
+		title := strings.NewReplacer(`[`, `\[`, `]`, `\]`).Replace(pg.title)
+		fmt.Fprintf(&md, "* [%s](%s)\n", title, link)
+	}
+
+	htmlData, err := parseContent(md.Bytes(), "index.md", tFname, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+
+	return saveFile(filepath.Join(outDir, "index.html"), htmlData)
+}
+
+// copyFile copies the file src to dst, creating its directory.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createSite creates a directory of markdown files and assets for testing.
+func createSite(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		fname := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestBuildSite(t *testing.T) {
+	dir := createSite(t, map[string]string{
+		"intro.md":       "# Intro\n\nSee [setup](guide/setup.md#install) and [Go](https://go.dev/doc.md).\n",
+		"guide/setup.md": "# Setup\n\nBack to [intro](../intro.md?x=1).\n\n![logo](../img/logo.png)\n",
+		"img/logo.png":   "png",
+		".git/config":    "ignored",
+	})
+	outDir := filepath.Join(dir, "_site")
+
+	var out bytes.Buffer
+	if err := buildSite(dir, outDir, "", renderOptions{}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	expOut := "Rendered 2 pages into " + outDir + "\n"
+	if out.String() != expOut {
+		t.Errorf("Expected output %q, got %q", expOut, out.String())
+	}
+
+	testCases := []struct {
+		name   string
+		file   string
+		expect []string
+	}{
+		{name: "Page", file: "intro.html",
+			expect: []string{`href="guide/setup.html#install"`, `href="https://go.dev/doc.md"`}},
+		{name: "SubdirPage", file: "guide/setup.html",
+			expect: []string{`href="../intro.html?x=1"`, `src="../img/logo.png"`}},
+		{name: "Asset", file: "img/logo.png", expect: []string{"png"}},
+		{name: "Index", file: "index.html",
+			expect: []string{`<a href="guide/setup.html"`, ">Setup</a>", `<a href="intro.html"`, ">Intro</a>"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(tc.file)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, exp := range tc.expect {
+				if !strings.Contains(string(data), exp) {
+					t.Errorf("Expected %s to contain %q, got:\n%s", tc.file, exp, data)
+				}
+			}
+		})
+	}
+
+	for _, name := range []string{".git/config", "_site/index.html"} {
+		if _, err := os.Stat(filepath.Join(outDir, filepath.FromSlash(name))); err == nil {
+			t.Errorf("Expected %s to be skipped", name)
+		}
+	}
+}
+
+func TestBuildSiteIndex(t *testing.T) {
+	dir := createSite(t, map[string]string{
+		"index.md": "# Home\n\nOur own index.\n",
+	})
+	outDir := t.TempDir()
+
+	if err := buildSite(dir, outDir, "", renderOptions{}, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "Our own index.") {
+		t.Errorf("Expected the index.md page to be kept, got:\n%s", data)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// slidesTheme is the theme decks are rendered with, unless
+// a template or another theme is given.
+const slidesTheme = "slides"
+
+// Private use characters marking, in a paragraph of their own, where
+// slides and their speaker notes start, until the deck is rendered.
+const (
+	slideMark = "\uE002"
+	notesMark = "\uE003"
+)
+
+var (
+	// slideSepRe matches the lines separating slides.
+	slideSepRe = regexp.MustCompile(`^---[ \t]*\r?\n?$`)
+	// notesRe matches the line starting the speaker notes of a slide.
+	notesRe = regexp.MustCompile(`^Notes?:[ \t]*`)
+	// slideClassRe matches the classes of the deck markup.
+	slideClassRe = regexp.MustCompile(`^(slide|notes)$`)
+)
+
+// markSlides marks the start of the slides of the markdown input, split
+// by --- lines, and of their speaker notes, starting with a Note: line,
+// outside of fenced code blocks. A --- line always separates slides,
+// rather than underlining a heading.
+func markSlides(input []byte) []byte {
+	var out []byte
+	fence := ""
+
+	for _, line := range bytes.SplitAfter(input, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " ")
+		indented := len(line)-len(trimmed) >= 4
+
+		switch {
+		case fence != "":
+			if !indented && bytes.HasPrefix(trimmed, []byte(fence)) &&
+				len(bytes.TrimSpace(bytes.TrimLeft(trimmed, fence[:1]))) == 0 {
+				fence = ""
+			}
+		case !indented && fenceRe.Match(trimmed):
+			fence = string(fenceRe.Find(trimmed))
+		case slideSepRe.Match(line):
+			out = append(out, "\n\n"+slideMark+"\n\n"...)
+			continue
+		case notesRe.Match(line):
+			out = append(out, "\n\n"+notesMark+"\n\n"...)
+			line = notesRe.ReplaceAll(line, nil)
+		}
+
+		out = append(out, line...)
+	}
+
+	return out
+}
+
+// deckSections wraps the slides of the HTML rendered from markSlides
+// output into sections, and their speaker notes into asides.
+func deckSections(output []byte) []byte {
+	slide := []byte("<p>" + slideMark + "</p>\n")
+	notes := []byte("<p>" + notesMark + "</p>\n")
+
+	out := []byte(`<section class="slide">` + "\n")
+	inNotes := false
+
+	for len(output) > 0 {
+		i := bytes.Index(output, slide)
+		j := bytes.Index(output, notes)
+
+		switch {
+		case j >= 0 && (i < 0 || j < i):
+			out = append(out, output[:j]...)
+			if !inNotes {
+				out = append(out, `<aside class="notes">`+"\n"...)
+				inNotes = true
+			}
+			output = bytes.TrimPrefix(output[j+len(notes):], []byte("\n"))
+		case i >= 0:
+			out = append(out, output[:i]...)
+			if inNotes {
+				out = append(out, "</aside>\n"...)
+				inNotes = false
+			}
+			out = append(out, "</section>\n"+`<section class="slide">`+"\n"...)
+			output = bytes.TrimPrefix(output[i+len(slide):], []byte("\n"))
+		default:
+			out = append(out, output...)
+			output = nil
+		}
+	}
+
+	if inNotes {
+		out = append(out, "</aside>\n"...)
+	}
+
+	return append(out, "</section>\n"...)
+}
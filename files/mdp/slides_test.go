@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeckSections(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  string
+		expect string
+	}{
+		{name: "Single", input: "# Title\n",
+			expect: "<section class=\"slide\">\n<h1>Title</h1>\n</section>\n"},
+		{name: "Separators", input: "# One\n---\n# Two\n\n---\n\nText\n",
+			expect: "<section class=\"slide\">\n<h1>One</h1>\n\n</section>\n<section class=\"slide\">\n" +
+				"<h1>Two</h1>\n\n</section>\n<section class=\"slide\">\n<p>Text</p>\n</section>\n"},
+		{name: "Notes", input: "# One\n\nNote: say *hi*\nand more\n---\n# Two\n",
+			expect: "<section class=\"slide\">\n<h1>One</h1>\n\n<aside class=\"notes\">\n<p>say <em>hi</em>\nand more</p>\n\n</aside>\n" +
+				"</section>\n<section class=\"slide\">\n<h1>Two</h1>\n</section>\n"},
+		{name: "FencedCode", input: "```yaml\n---\nNote: kept\n```\n",
+			expect: "<section class=\"slide\">\n<pre><code class=\"language-yaml\">---\nNote: kept\n</code></pre>\n</section>\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, _, _, _, err := renderMarkdown(markSlides([]byte(tc.input)), renderOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result := string(deckSections(output)); result != tc.expect {
+				t.Errorf("Expected:\n%q\ngot:\n%q", tc.expect, result)
+			}
+		})
+	}
+}
+
+func TestRunSlides(t *testing.T) {
+	dir := createSite(t, map[string]string{
+		"deck.md": "---\ntitle: Our deck\n---\n# Welcome\n\n![logo](logo.png)\n\nNotes: greet everyone\n\n---\n\n## Agenda\n\n* One\n* Two\n",
+	})
+	createPNG(t, filepath.Join(dir, "logo.png"))
+	outName := filepath.Join(dir, "deck.html")
+
+	if err := run(filepath.Join(dir, "deck.md"), "", outName, formatSlides, renderOptions{}, &bytes.Buffer{}, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(outName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := string(data)
+
+	if n := strings.Count(result, `<section class="slide">`); n != 2 {
+		t.Errorf("Expected 2 slides, got %d:\n%s", n, result)
+	}
+	for _, exp := range []string{
+		"<title>Our deck</title>",
+		`<body class="theme-slides">`,
+		"<aside class=\"notes\">\n<p>greet everyone</p>",
+		`src="data:image/png;base64,`,
+		`document.addEventListener("keydown"`,
+		"@media print",
+	} {
+		if !strings.Contains(result, exp) {
+			t.Errorf("Expected %q, got:\n%s", exp, result)
+		}
+	}
+}
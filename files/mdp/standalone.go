@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// imgSrcRe matches the source of img tags.
+	imgSrcRe = regexp.MustCompile(`(<img\b[^>]*?\ssrc=")([^"]*)(")`)
+	// stylesheetRe matches link tags.
+	stylesheetRe = regexp.MustCompile(`<link\b[^>]*>`)
+	// attrRe matches the double quoted attributes of a tag.
+	attrRe = regexp.MustCompile(`\s([a-zA-Z-]+)="([^"]*)"`)
+)
+
+// inlineAssets returns the page with its local images inlined as data
+// URIs and its local stylesheets embedded, so it can be shared as a
+// single file. Paths are relative to baseDir. Remote images and
+// stylesheets are left linked.
+func inlineAssets(page []byte, baseDir string) ([]byte, error) {
+	var err error
+
+	page = imgSrcRe.ReplaceAllFunc(page, func(m []byte) []byte {
+		parts := imgSrcRe.FindSubmatch(m)
+		src := string(parts[2])
+		if err != nil || !isRelative(src) {
+			return m
+		}
+
+		var data []byte
+		if data, err = os.ReadFile(assetPath(baseDir, src)); err != nil {
+			return m
+		}
+
+		uri := "data:" + mimeType(src, data) + ";base64," + base64.StdEncoding.EncodeToString(data)
+		return bytes.Join([][]byte{parts[1], []byte(uri), parts[3]}, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page = stylesheetRe.ReplaceAllFunc(page, func(m []byte) []byte {
+		attrs := make(map[string]string)
+		for _, a := range attrRe.FindAllSubmatch(m, -1) {
+			attrs[strings.ToLower(string(a[1]))] = string(a[2])
+		}
+
+		href := attrs["href"]
+		if err != nil || !strings.EqualFold(attrs["rel"], "stylesheet") || !isRelative(href) {
+			return m
+		}
+
+		var css []byte
+		if css, err = os.ReadFile(assetPath(baseDir, href)); err != nil {
+			return m
+		}
+
+		return []byte(fmt.Sprintf("<style>\n%s</style>", css))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// assetPath returns the file a relative link of the page points
+// to, without its query or fragment.
+func assetPath(baseDir, link string) string {
+	link = html.UnescapeString(link)
+	if i := strings.IndexAny(link, "?#"); i >= 0 {
+		link = link[:i]
+	}
+	if p, err := url.PathUnescape(link); err == nil {
+		link = p
+	}
+
+	return filepath.Join(baseDir, filepath.FromSlash(link))
+}
+
+// mimeType returns the media type of the asset, by its
+// extension or else by its contents.
+func mimeType(name string, data []byte) string {
+	if t := mime.TypeByExtension(filepath.Ext(assetPath("", name))); t != "" {
+		return t
+	}
+
+	return http.DetectContentType(data)
+}
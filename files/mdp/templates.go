@@ -0,0 +1,178 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// themes holds the built-in themes, as a template directory
+// with a layout for each theme.
+//
+//go:embed themes
+var themes embed.FS
+
+// Layout of template directories: the page is rendered with one
+// of the layouts, which can include any of the partials by their
+// path, such as {{ template "partials/nav.html.tmpl" . }}.
+const (
+	layoutsDir    = "layouts"
+	partialsDir   = "partials"
+	defaultLayout = "default"
+	layoutExt     = ".html.tmpl"
+)
+
+// wordsPerMinute is the reading speed readingTime assumes.
+const wordsPerMinute = 200
+
+// tagRe matches HTML tags.
+var tagRe = regexp.MustCompile(`<[^>]*>`)
+
+// templateFuncs are the helper functions available to every template.
+var templateFuncs = template.FuncMap{
+	"date":        formatDate,
+	"wordCount":   wordCount,
+	"readingTime": readingTime,
+}
+
+// loadTemplate returns the template pages are rendered with: the tFname
+// file or template directory, else the built-in theme, else the default
+// template. With a template directory, layout picks the layout.
+func loadTemplate(tFname, theme, layout string) (*template.Template, error) {
+	if tFname != "" {
+		info, err := os.Stat(tFname)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			return dirTemplate(os.DirFS(tFname), layout)
+		}
+		return template.New(filepath.Base(tFname)).Funcs(templateFuncs).ParseFiles(tFname)
+	}
+
+	if theme != "" {
+		names, err := themeNames()
+		if err != nil {
+			return nil, err
+		}
+		if i := sort.SearchStrings(names, theme); i == len(names) || names[i] != theme {
+			return nil, fmt.Errorf("%w: %s (available: %s)", ErrInvalidTheme, theme, strings.Join(names, ", "))
+		}
+
+		fsys, err := fs.Sub(themes, "themes")
+		if err != nil {
+			return nil, err
+		}
+		return dirTemplate(fsys, theme)
+	}
+
+	return template.New("mdp").Funcs(templateFuncs).Parse(defaultTemplate)
+}
+
+// themeNames returns the names of the built-in themes, sorted.
+func themeNames() ([]string, error) {
+	files, err := fs.Glob(themes, path.Join("themes", layoutsDir, "*"+layoutExt))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, strings.TrimSuffix(path.Base(f), layoutExt))
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// dirTemplate parses the layout of a template directory, the default one
+// if empty, along with every partial under the partials directory.
+func dirTemplate(fsys fs.FS, layout string) (*template.Template, error) {
+	if layout == "" {
+		layout = defaultLayout
+	}
+
+	name := path.Join(layoutsDir, layout+layoutExt)
+	data, err := fs.ReadFile(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidLayout, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := template.New(name).Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	err = fs.WalkDir(fsys, partialsDir, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == partialsDir {
+			return fs.SkipDir
+		}
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".tmpl") {
+			return err
+		}
+
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		_, err = t.New(p).Parse(string(data))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// formatDate formats a date, such as the front matter date, with the
+// layout of the time package. Values that aren't dates are kept as text.
+func formatDate(layout string, value any) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(layout)
+	case string:
+		for _, l := range []string{"2006-01-02", time.RFC3339, "2006-01-02 15:04:05"} {
+			if t, err := time.Parse(l, v); err == nil {
+				return t.Format(layout)
+			}
+		}
+		return v
+	case nil:
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
+// wordCount returns the number of words of the text or HTML, such as the body.
+func wordCount(value any) int {
+	text := fmt.Sprint(value)
+	if h, ok := value.(template.HTML); ok {
+		text = html.UnescapeString(tagRe.ReplaceAllString(string(h), " "))
+	}
+
+	return len(strings.Fields(text))
+}
+
+// readingTime returns the minutes it takes to read the text or HTML, at least one.
+func readingTime(value any) int {
+	if minutes := (wordCount(value) + wordsPerMinute - 1) / wordsPerMinute; minutes > 1 {
+		return minutes
+	}
+
+	return 1
+}
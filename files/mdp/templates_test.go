@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"html/template"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const themeInput = "---\ntitle: Release notes\nauthor: Ann\ndate: 2024-03-05\ntags: [go, cli]\n---\n# Notes\n\nSome *text*.\n"
+
+func TestThemes(t *testing.T) {
+	names, err := themeNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"dark", "light", "print", "slides"}; strings.Join(names, ",") != strings.Join(exp, ",") {
+		t.Fatalf("Expected themes %v, got %v", exp, names)
+	}
+
+	for _, theme := range names {
+		t.Run(theme, func(t *testing.T) {
+			result, err := parseContent([]byte(themeInput), "", "", renderOptions{theme: theme})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, exp := range []string{
+				"<title>Release notes</title>",
+				`<body class="theme-` + theme + `">`,
+				`<time datetime="2024-03-05">March 5, 2024</time> · 1 min read`,
+				"<li>cli</li>",
+				"<p>Some <em>text</em>.</p>",
+				"max-width:",
+			} {
+				if !strings.Contains(string(result), exp) {
+					t.Errorf("Expected %q, got:\n%s", exp, result)
+				}
+			}
+		})
+	}
+
+	_, err = parseContent([]byte(themeInput), "", "", renderOptions{theme: "neon"})
+	if !errors.Is(err, ErrInvalidTheme) {
+		t.Errorf("Expected error %q, got %q", ErrInvalidTheme, err)
+	}
+}
+
+func TestTemplateDir(t *testing.T) {
+	dir := createSite(t, map[string]string{
+		"layouts/default.html.tmpl":     `<html>{{ template "partials/nav.html.tmpl" . }}<main>{{ .Body }}</main></html>`,
+		"layouts/post.html.tmpl":        `<article>{{ .Title }}, {{ wordCount .Body }} words{{ .Body }}</article>`,
+		"partials/nav.html.tmpl":        `<nav>{{ template "partials/links/home.html.tmpl" }}</nav>`,
+		"partials/links/home.html.tmpl": `<a href="/">Home</a>`,
+	})
+
+	testCases := []struct {
+		name   string
+		input  string
+		expect string
+		expErr error
+	}{
+		{name: "DefaultLayout", input: "Hello.\n",
+			expect: "<html><nav><a href=\"/\">Home</a></nav><main><p>Hello.</p>\n</main></html>"},
+		{name: "FrontMatterLayout", input: "---\ntitle: Post\nlayout: post\n---\nHello there.\n",
+			expect: "<article>Post, 2 words<p>Hello there.</p>\n</article>"},
+		{name: "MissingLayout", input: "---\nlayout: page\n---\nHello.\n", expErr: ErrInvalidLayout},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := parseContent([]byte(tc.input), "", dir, renderOptions{theme: "dark"})
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Fatalf("Expected error %q, got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(result) != tc.expect {
+				t.Errorf("Expected %q, got %q", tc.expect, result)
+			}
+		})
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	longBody := template.HTML("<p>" + strings.Repeat("word ", 450) + "</p>")
+
+	testCases := []struct {
+		name   string
+		result any
+		expect any
+	}{
+		{name: "DateString", result: formatDate("Jan 2, 2006", "2024-03-05"), expect: "Mar 5, 2024"},
+		{name: "DateRFC3339", result: formatDate("2006-01-02", "2024-03-05T10:00:00Z"), expect: "2024-03-05"},
+		{name: "DateTime", result: formatDate("2006", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)), expect: "2023"},
+		{name: "DateText", result: formatDate("2006", "spring"), expect: "spring"},
+		{name: "DateEmpty", result: formatDate("2006", nil), expect: ""},
+		{name: "WordCountHTML", result: wordCount(template.HTML("<p>One <em>two</em>&nbsp;three</p>")), expect: 3},
+		{name: "WordCountText", result: wordCount("one two"), expect: 2},
+		{name: "ReadingTime", result: readingTime(longBody), expect: 3},
+		{name: "ReadingTimeShort", result: readingTime(""), expect: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.result != tc.expect {
+				t.Errorf("Expected %v, got %v", tc.expect, tc.result)
+			}
+		})
+	}
+}
+
+func TestTemplateFileFuncs(t *testing.T) {
+	dir := createSite(t, map[string]string{
+		"page.html.tmpl": `{{ readingTime .Body }} min, {{ date "2006" .Date }}`,
+	})
+
+	result, err := parseContent([]byte("---\ndate: 2021-06-01\n---\nHi.\n"), "", filepath.Join(dir, "page.html.tmpl"), renderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != "1 min, 2021" {
+		t.Errorf("Expected %q, got %q", "1 min, 2021", result)
+	}
+}
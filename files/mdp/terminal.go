@@ -0,0 +1,670 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/russross/blackfriday/v2"
+	"golang.org/x/term"
+)
+
+// Terminal layout, in columns.
+const (
+	defaultTermWidth = 80 // width when the terminal's is unknown
+	minTermWidth     = 20 // narrowest text, however deep the indentation
+	codeIndent       = "  "
+	tabWidth         = 4
+)
+
+// defaultPager pages the terminal output, unless PAGER is set.
+const defaultPager = "less"
+
+// ANSI select graphic rendition parameters.
+const (
+	sgrBold      = "1"
+	sgrFaint     = "2"
+	sgrItalic    = "3"
+	sgrUnderline = "4"
+	sgrStrike    = "9"
+	sgrGreen     = "32"
+	sgrYellow    = "33"
+	sgrBlue      = "34"
+	sgrMagenta   = "35"
+	sgrCyan      = "36"
+	ansiReset    = "\x1b[0m"
+)
+
+var (
+	// ansiRe matches ANSI escape sequences.
+	ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	// alertTitleRe matches the title of the alert markup.
+	alertTitleRe = regexp.MustCompile(`<p class="markdown-alert-title">([^<]*)</p>`)
+)
+
+// headingStyles holds the SGR parameters of each heading level.
+var headingStyles = [...][]string{
+	1: {sgrBold, sgrUnderline, sgrMagenta},
+	2: {sgrBold, sgrMagenta},
+	3: {sgrBold, sgrCyan},
+	4: {sgrBold},
+	5: {sgrBold},
+	6: {sgrBold, sgrFaint},
+}
+
+// termWriter renders the nodes of a markdown document as text styled
+// with ANSI escape sequences, wrapped to the width of the terminal.
+type termWriter struct {
+	out   bytes.Buffer
+	width int           // width of the lines, in columns
+	style *chroma.Style // highlighting style of code blocks, nil to disable it
+
+	styles  [][]string // SGR parameters of the open inline nodes
+	words   []termWord // words of the block being written
+	space   bool       // a space precedes the next word
+	margins []string   // line prefix of each open list item, quote and alert
+	marker  string     // bullet or number replacing the last margin on the next line
+	blank   bool       // a blank line is due before the next line
+	lists   []int      // number of the next item of each open list, 0 for bullets
+	alerts  int        // nesting of alerts
+}
+
+// termWord is a word of styled text.
+type termWord struct {
+	text  string // text, with its escape sequences
+	width int    // width of the text, in columns
+}
+
+// renderTerminal renders the markdown input as text wrapped to opts.width,
+// styled with ANSI escape sequences with opts.color, and writes it to out.
+// Front matter sets the document title.
+func renderTerminal(input []byte, opts renderOptions, out io.Writer) error {
+	fm, input, err := splitFrontMatter(input)
+	if err != nil {
+		return err
+	}
+
+	w := &termWriter{width: opts.width}
+	if w.width <= 0 {
+		w.width = defaultTermWidth
+	}
+	if opts.style != "" {
+		if w.style, err = chromaStyle(opts.style); err != nil {
+			return err
+		}
+	}
+
+	if fm.Title != "" {
+		w.push(headingStyles[1]...)
+		w.text(fm.Title)
+		w.pop()
+		w.flush()
+		w.blank = true
+	}
+
+	// Math is written as its source.
+	opts.math = false
+	doc, _ := parseMarkdown(input, opts)
+	doc.Walk(w.node)
+
+	data := w.out.Bytes()
+	if !opts.color {
+		data = ansiRe.ReplaceAll(data, nil)
+	}
+
+	_, err = out.Write(data)
+	return err
+}
+
+// node renders a node of the document, as a blackfriday.NodeVisitor.
+func (w *termWriter) node(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+	switch node.Type {
+	case blackfriday.Heading:
+		if entering {
+			w.push(headingStyles[node.Level]...)
+			w.text(strings.Repeat("#", node.Level) + " ")
+		} else {
+			w.pop()
+			w.endBlock(node)
+		}
+	case blackfriday.Paragraph:
+		if !entering {
+			w.endBlock(node)
+		}
+	case blackfriday.Text:
+		w.text(string(node.Literal))
+	case blackfriday.Softbreak:
+		w.space = true
+	case blackfriday.Hardbreak:
+		w.flush()
+	case blackfriday.Emph:
+		w.toggle(entering, sgrItalic)
+	case blackfriday.Strong:
+		w.toggle(entering, sgrBold)
+	case blackfriday.Del:
+		w.toggle(entering, sgrStrike)
+	case blackfriday.Code:
+		w.push(sgrYellow)
+		w.text(string(node.Literal))
+		w.pop()
+	case blackfriday.Link:
+		return w.link(node, entering)
+	case blackfriday.Image:
+		alt := plainText(node)
+		if alt == "" {
+			alt = string(node.LinkData.Destination)
+		}
+		w.push(sgrFaint)
+		w.text("[image: " + alt + "]")
+		w.pop()
+		return blackfriday.SkipChildren
+	case blackfriday.HTMLSpan:
+		if entering && bytes.HasPrefix(node.Literal, []byte(`<input type="checkbox"`)) {
+			w.checkbox(bytes.Contains(node.Literal, []byte("checked")))
+		}
+	case blackfriday.HTMLBlock:
+		w.htmlBlock(node)
+	case blackfriday.CodeBlock:
+		for _, line := range w.highlight(node) {
+			w.line(codeIndent + line)
+		}
+		w.endBlock(node)
+	case blackfriday.List:
+		w.list(node, entering)
+	case blackfriday.Item:
+		w.item(entering)
+	case blackfriday.BlockQuote:
+		if entering {
+			w.pushMargin(styled("│", sgrFaint) + " ")
+		} else {
+			w.margins = w.margins[:len(w.margins)-1]
+			w.blank = true
+		}
+	case blackfriday.HorizontalRule:
+		w.rule()
+	case blackfriday.Table:
+		w.table(node)
+		return blackfriday.SkipChildren
+	}
+
+	return blackfriday.GoToNext
+}
+
+// push opens a style, applied to the text until it's popped.
+func (w *termWriter) push(params ...string) {
+	w.styles = append(w.styles, params)
+}
+
+// pop closes the last style opened.
+func (w *termWriter) pop() {
+	w.styles = w.styles[:len(w.styles)-1]
+}
+
+// toggle opens the style when entering a node and closes it when leaving it.
+func (w *termWriter) toggle(entering bool, params ...string) {
+	if entering {
+		w.push(params...)
+		return
+	}
+	w.pop()
+}
+
+// styled returns the text with the style of the SGR parameters.
+func styled(text string, params ...string) string {
+	if len(params) == 0 || text == "" {
+		return text
+	}
+
+	return "\x1b[" + strings.Join(params, ";") + "m" + text + ansiReset
+}
+
+// text adds the text, in the open styles, to the block being written.
+func (w *termWriter) text(s string) {
+	var params []string
+	for _, p := range w.styles {
+		params = append(params, p...)
+	}
+
+	s = stripControls(s)
+	for s != "" {
+		if r, size := utf8.DecodeRuneInString(s); unicode.IsSpace(r) {
+			w.space, s = true, s[size:]
+			continue
+		}
+
+		i := strings.IndexFunc(s, unicode.IsSpace)
+		if i < 0 {
+			i = len(s)
+		}
+
+		// Text following the last word without a space is part of it.
+		if w.space || len(w.words) == 0 {
+			w.words = append(w.words, termWord{})
+			w.space = false
+		}
+		word := &w.words[len(w.words)-1]
+		word.text += styled(s[:i], params...)
+		word.width += utf8.RuneCountInString(s[:i])
+
+		s = s[i:]
+	}
+}
+
+// flush writes the words of the current block, wrapped into lines.
+func (w *termWriter) flush() {
+	width := w.textWidth()
+
+	var line strings.Builder
+	n := 0
+	for _, word := range w.words {
+		if n > 0 && n+1+word.width > width {
+			w.line(line.String())
+			line.Reset()
+			n = 0
+		}
+		if n > 0 {
+			line.WriteByte(' ')
+			n++
+		}
+		line.WriteString(word.text)
+		n += word.width
+	}
+	if n > 0 {
+		w.line(line.String())
+	}
+
+	w.words, w.space = nil, false
+}
+
+// textWidth returns the width left to the text by the margins.
+func (w *termWriter) textWidth() int {
+	width := w.width - visibleWidth(strings.Join(w.margins, ""))
+	if width < minTermWidth {
+		return minTermWidth
+	}
+
+	return width
+}
+
+// pushMargin adds a margin to the next lines, after the blank line due.
+func (w *termWriter) pushMargin(margin string) {
+	w.writeBlank()
+	w.margins = append(w.margins, margin)
+}
+
+// writeBlank writes the blank line due, if any, with the margins.
+func (w *termWriter) writeBlank() {
+	if !w.blank {
+		return
+	}
+
+	w.out.WriteString(strings.TrimRight(strings.Join(w.margins, ""), " "))
+	w.out.WriteByte('\n')
+	w.blank = false
+}
+
+// line writes a line after the margins, and the blank line due before it.
+func (w *termWriter) line(text string) {
+	w.writeBlank()
+
+	margins := strings.Join(w.margins, "")
+	if w.marker != "" {
+		margins = strings.Join(w.margins[:len(w.margins)-1], "") + w.marker
+		w.marker = ""
+	}
+
+	w.out.WriteString(strings.TrimRight(margins+text, " "))
+	w.out.WriteByte('\n')
+}
+
+// endBlock writes the current block, with a blank line due after
+// it unless it's inside an item of a tight list.
+func (w *termWriter) endBlock(node *blackfriday.Node) {
+	w.flush()
+
+	parent := node.Parent
+	w.blank = parent == nil || parent.Type != blackfriday.Item ||
+		parent.Parent == nil || !parent.Parent.Tight
+}
+
+// link writes the text of a link followed by its destination, unless
+// the text is the destination. Footnote references are written as [n].
+func (w *termWriter) link(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+	if node.NoteID != 0 {
+		w.push(sgrFaint)
+		w.text(fmt.Sprintf("[%d]", node.NoteID))
+		w.pop()
+		return blackfriday.SkipChildren
+	}
+
+	if entering {
+		w.push(sgrUnderline, sgrBlue)
+		return blackfriday.GoToNext
+	}
+	w.pop()
+
+	dest := string(node.LinkData.Destination)
+	if text := plainText(node); dest != text && "mailto:"+text != dest {
+		w.push(sgrFaint)
+		w.text(" (" + dest + ")")
+		w.pop()
+	}
+
+	return blackfriday.GoToNext
+}
+
+// checkbox writes the checkbox of a task list item.
+func (w *termWriter) checkbox(checked bool) {
+	if checked {
+		w.push(sgrGreen)
+		w.text("[x]")
+		w.pop()
+		return
+	}
+
+	w.text("[ ]")
+}
+
+// htmlBlock writes the text of raw HTML. The markup of alerts is written
+// as a title, with a bar in the margin of the alert.
+func (w *termWriter) htmlBlock(node *blackfriday.Node) {
+	if m := alertTitleRe.FindSubmatch(node.Literal); m != nil {
+		w.pushMargin(styled("│", sgrYellow) + " ")
+		w.alerts++
+
+		w.push(sgrBold, sgrYellow)
+		w.text(string(m[1]))
+		w.pop()
+		w.flush()
+		return
+	}
+
+	if w.alerts > 0 && string(node.Literal) == "</div>" {
+		w.margins = w.margins[:len(w.margins)-1]
+		w.alerts--
+		w.blank = true
+		return
+	}
+
+	text := strings.TrimSpace(html.UnescapeString(tagRe.ReplaceAllString(string(node.Literal), "")))
+	if text == "" {
+		return
+	}
+	w.text(text)
+	w.endBlock(node)
+}
+
+// highlight returns the lines of a code block, highlighted if its
+// language is known to chroma and a style is set.
+func (w *termWriter) highlight(node *blackfriday.Node) []string {
+	code := strings.TrimSuffix(stripControls(string(node.Literal)), "\n")
+	code = strings.ReplaceAll(code, "\t", strings.Repeat(" ", tabWidth))
+
+	plain := strings.Split(code, "\n")
+	for i, line := range plain {
+		plain[i] = styled(line, sgrYellow)
+	}
+
+	lang := strings.Fields(string(node.Info))
+	if w.style == nil || len(lang) == 0 {
+		return plain
+	}
+
+	lexer := lexers.Get(lang[0])
+	if lexer == nil {
+		return plain
+	}
+
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return plain
+	}
+
+	// Lines are highlighted one by one, so each can have its margins.
+	var lines []string
+	for _, tokens := range chroma.SplitTokensIntoLines(it.Tokens()) {
+		var buf strings.Builder
+		if err := formatters.TTY256.Format(&buf, w.style, chroma.Literator(tokens...)); err != nil {
+			return plain
+		}
+		lines = append(lines, strings.ReplaceAll(buf.String(), "\n", ""))
+	}
+
+	return lines
+}
+
+// list opens or closes a list. The footnotes are set apart by a rule.
+func (w *termWriter) list(node *blackfriday.Node, entering bool) {
+	if !entering {
+		w.lists = w.lists[:len(w.lists)-1]
+		if node.Parent.Type != blackfriday.Item {
+			w.blank = true
+		}
+		return
+	}
+
+	if node.IsFootnotesList {
+		w.rule()
+	}
+
+	start := 0
+	if node.ListFlags&blackfriday.ListTypeOrdered != 0 {
+		start = 1
+	}
+	w.lists = append(w.lists, start)
+}
+
+// item opens a list item, with its bullet or number hanging in
+// the margin of its first line, or closes it.
+func (w *termWriter) item(entering bool) {
+	if !entering {
+		// Footnotes hold their text without a paragraph,
+		// and empty items still have their bullet.
+		w.flush()
+		if w.marker != "" {
+			w.line("")
+		}
+		w.margins = w.margins[:len(w.margins)-1]
+		return
+	}
+
+	n := &w.lists[len(w.lists)-1]
+
+	mark := "•"
+	if *n > 0 {
+		mark = strconv.Itoa(*n) + "."
+		*n++
+	}
+	mark += " "
+
+	w.pushMargin(strings.Repeat(" ", utf8.RuneCountInString(mark)))
+	w.marker = styled(mark, sgrCyan)
+}
+
+// rule writes a horizontal rule across the text width.
+func (w *termWriter) rule() {
+	w.line(styled(strings.Repeat("─", w.textWidth()), sgrFaint))
+	w.blank = true
+}
+
+// table writes a table with columns as wide as their cells, shrinking
+// the widest ones to fit the text width.
+func (w *termWriter) table(node *blackfriday.Node) {
+	var rows [][]string
+	var aligns []blackfriday.CellAlignFlags
+	var header int
+
+	node.Walk(func(n *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		switch {
+		case entering && n.Type == blackfriday.TableRow:
+			rows = append(rows, nil)
+		case entering && n.Type == blackfriday.TableCell:
+			row := &rows[len(rows)-1]
+			*row = append(*row, stripControls(plainText(n)))
+			if len(rows) == 1 {
+				aligns = append(aligns, n.Align)
+				if n.IsHeader {
+					header = 1
+				}
+			}
+			return blackfriday.SkipChildren
+		}
+		return blackfriday.GoToNext
+	})
+
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return
+	}
+
+	widths := make([]int, len(aligns))
+	for _, row := range rows {
+		for i, cell := range row {
+			if i < len(widths) && utf8.RuneCountInString(cell) > widths[i] {
+				widths[i] = utf8.RuneCountInString(cell)
+			}
+		}
+	}
+
+	// Columns are separated by " │ ".
+	total := 3 * (len(widths) - 1)
+	for _, width := range widths {
+		total += width
+	}
+	for total > w.textWidth() {
+		widest := 0
+		for i := range widths {
+			if widths[i] > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= 3 {
+			break
+		}
+		widths[widest]--
+		total--
+	}
+
+	sep := styled("│", sgrFaint)
+	for i, row := range rows {
+		cells := make([]string, len(widths))
+		for j := range widths {
+			cell := ""
+			if j < len(row) {
+				cell = row[j]
+			}
+			cell = pad(truncate(cell, widths[j]), widths[j], aligns[j])
+			if j == len(widths)-1 {
+				cell = strings.TrimRight(cell, " ")
+			}
+			if i < header {
+				cell = styled(cell, sgrBold)
+			}
+			cells[j] = cell
+		}
+		w.line(strings.Join(cells, " "+sep+" "))
+
+		if i == header-1 {
+			rules := make([]string, len(widths))
+			for j, width := range widths {
+				rules[j] = strings.Repeat("─", width)
+			}
+			w.line(styled(strings.Join(rules, "─┼─"), sgrFaint))
+		}
+	}
+
+	w.blank = true
+}
+
+// truncate shortens the text to width columns, ending it with an ellipsis.
+func truncate(text string, width int) string {
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+
+	return string([]rune(text)[:width-1]) + "…"
+}
+
+// pad pads the text with spaces to width columns, aligned as the table cell.
+func pad(text string, width int, align blackfriday.CellAlignFlags) string {
+	space := width - utf8.RuneCountInString(text)
+
+	switch align {
+	case blackfriday.TableAlignmentRight:
+		return strings.Repeat(" ", space) + text
+	case blackfriday.TableAlignmentCenter:
+		return strings.Repeat(" ", space/2) + text + strings.Repeat(" ", space-space/2)
+	}
+
+	return text + strings.Repeat(" ", space)
+}
+
+// visibleWidth returns the width of the text without its escape sequences.
+func visibleWidth(text string) int {
+	return utf8.RuneCountInString(ansiRe.ReplaceAllString(text, ""))
+}
+
+// stripControls removes the control characters but tabs and newlines, so
+// escape sequences in the document can't take over the terminal.
+func stripControls(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\t' && r != '\n' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// terminalWidth returns the width of the terminal f, or of COLUMNS if
+// it's not a terminal.
+func terminalWidth(f *os.File) int {
+	if width, _, err := term.GetSize(int(f.Fd())); err == nil && width > 0 {
+		return width
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+
+	return defaultTermWidth
+}
+
+// writePaged writes data to out, through the PAGER if out is a terminal.
+func writePaged(data []byte, out io.Writer) error {
+	f, ok := out.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		_, err := out.Write(data)
+		return err
+	}
+
+	args := strings.Fields(os.Getenv("PAGER"))
+	if len(args) == 0 {
+		args = []string{defaultPager}
+	}
+
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		// Without a pager, the terminal scrolls.
+		_, err := out.Write(data)
+		return err
+	}
+
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(data), f, os.Stderr
+
+	// Like git, have less keep the colors and exit if the output fits the screen.
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+
+	return cmd.Run()
+}
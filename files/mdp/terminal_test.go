@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRenderTerminal(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  string
+		expect string
+	}{
+		{name: "Wrap", input: "---\ntitle: Doc\n---\n# One\n\nSome *words* wrapped at the **width** of the `terminal`, at last.\n",
+			expect: "Doc\n\n# One\n\nSome words wrapped at the\nwidth of the terminal, at\nlast.\n"},
+		{name: "Links", input: "A [link](https://go.dev), <https://go.dev> and ![logo](logo.png).\n",
+			expect: "A link (https://go.dev),\nhttps://go.dev and [image:\nlogo].\n"},
+		{name: "Lists", input: "1. One\n2. Two\n   * Nested\n\n- [x] Done\n- [ ] Todo\n",
+			expect: "1. One\n2. Two\n   • Nested\n\n• [x] Done\n• [ ] Todo\n"},
+		{name: "Quote", input: "Text.\n\n> Quoted text long enough to wrap in the margin.\n",
+			expect: "Text.\n\n│ Quoted text long enough to\n│ wrap in the margin.\n"},
+		{name: "Alert", input: "> [!TIP]\n> Read this.\n",
+			expect: "│ Tip\n│ Read this.\n"},
+		{name: "Code", input: "```\nif x {\n\treturn\n}\n```\n",
+			expect: "  if x {\n      return\n  }\n"},
+		{name: "Table", input: "| Name | Count |\n|:-----|:-----:|\n| apples | 3 |\n",
+			expect: "Name   │ Count\n───────┼──────\napples │   3\n"},
+		{name: "TableShrink", input: "| Column | Text |\n|---|---|\n| a | very long text in the cell |\n",
+			expect: "Column │ Text\n───────┼──────────────────────\na      │ very long text in th…\n"},
+		{name: "Footnotes", input: "Text[^1].\n\n[^1]: Note.\n",
+			expect: "Text[1].\n\n──────────────────────────────\n\n1. Note.\n"},
+		{name: "Controls", input: "Safe \x1b[31mtext\x07.\n",
+			expect: "Safe [31mtext.\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			opts := renderOptions{extensions: allExtensions, width: 30}
+			if err := renderTerminal([]byte(tc.input), opts, &out); err != nil {
+				t.Fatal(err)
+			}
+
+			if result := ansiRe.ReplaceAllString(out.String(), ""); result != tc.expect {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tc.expect, result)
+			}
+		})
+	}
+}
+
+func TestRenderTerminalStyles(t *testing.T) {
+	var out bytes.Buffer
+	input := "# Title\n\n**Bold** and *italic*.\n\n```go\nreturn nil\n```\n"
+	if err := renderTerminal([]byte(input), renderOptions{style: "monokai", color: true}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, exp := range []string{
+		"\x1b[1;4;35mTitle\x1b[0m",
+		"\x1b[1mBold\x1b[0m",
+		"\x1b[3mitalic\x1b[0m",
+		"  \x1b[38;5;81mreturn\x1b[0m",
+	} {
+		if !strings.Contains(out.String(), exp) {
+			t.Errorf("Expected %q, got %q", exp, out.String())
+		}
+	}
+}
+
+func TestRenderTerminalPlain(t *testing.T) {
+	var out bytes.Buffer
+	input := "# Title\n\n**Bold**, [link](https://go.dev) and\n\n```go\nreturn nil\n```\n"
+	if err := renderTerminal([]byte(input), renderOptions{style: "monokai"}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "# Title\n\nBold, link (https://go.dev) and\n\n  return nil\n"
+	if out.String() != expect {
+		t.Errorf("Expected:\n%q\ngot:\n%q", expect, out.String())
+	}
+}
+
+func TestRunTerminal(t *testing.T) {
+	var out bytes.Buffer
+	if err := run(inputFile, "", "", formatTerminal, renderOptions{}, &out, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Just a test") {
+		t.Errorf("Expected the document to be written, got:\n%s", out.String())
+	}
+
+	outName := filepath.Join(t.TempDir(), "out.txt")
+	out.Reset()
+	if err := run(inputFile, "", outName, formatTerminal, renderOptions{}, &out, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != outName+"\n" {
+		t.Errorf("Expected %q, got %q", outName+"\n", out.String())
+	}
+	if _, err := os.Stat(outName); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHasDisplay(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("the display is only looked up on", runtime.GOOS)
+	}
+
+	t.Setenv("DISPLAY", "")
+	t.Setenv("WAYLAND_DISPLAY", "")
+	if hasDisplay() {
+		t.Error("Expected no display")
+	}
+
+	t.Setenv("DISPLAY", ":0")
+	if !hasDisplay() {
+		t.Error("Expected a display")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/russross/blackfriday/v2"
+)
+
+// headingIDRe matches the heading ids kept by the sanitizer.
+var headingIDRe = regexp.MustCompile(`^[\pL\pN_-]+$`)
+
+// defaultTOCDepth is the deepest heading level listed in the table of contents.
+const defaultTOCDepth = 3
+
+// heading is an entry of the table of contents.
+type heading struct {
+	level int
+	id    string
+	text  string
+}
+
+// setHeadingIDs gives every heading of the document a slug of its text
+// as id, unless it set its own, making duplicates unique with a numeric
+// suffix. It returns the headings up to level depth, in document order.
+func setHeadingIDs(doc *blackfriday.Node, depth int) []heading {
+	var headings []heading
+	used := make(map[string]bool)
+
+	doc.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if !entering || node.Type != blackfriday.Heading || node.IsTitleblock {
+			return blackfriday.GoToNext
+		}
+
+		text := strings.TrimSpace(plainText(node))
+
+		id := node.HeadingID
+		if id == "" {
+			id = blackfriday.SanitizedAnchorName(text)
+		}
+		if id == "" {
+			id = "section"
+		}
+		for base, i := id, 1; used[id]; i++ {
+			id = fmt.Sprintf("%s-%d", base, i)
+		}
+		used[id] = true
+		node.HeadingID = id
+
+		if node.Level <= depth {
+			headings = append(headings, heading{level: node.Level, id: id, text: text})
+		}
+
+		return blackfriday.SkipChildren
+	})
+
+	return headings
+}
+
+// plainText returns the text held by the node and its children.
+func plainText(node *blackfriday.Node) string {
+	var text strings.Builder
+
+	node.Walk(func(n *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if entering && (n.Type == blackfriday.Text || n.Type == blackfriday.Code) {
+			text.Write(n.Literal)
+		}
+		return blackfriday.GoToNext
+	})
+
+	return text.String()
+}
+
+// renderTOC writes the headings as lists of links to them,
+// nesting deeper headings in the item of the heading before them.
+func renderTOC(headings []heading) []byte {
+	if len(headings) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+
+	// Levels of the lists currently open.
+	var open []int
+
+	for _, h := range headings {
+		switch {
+		case len(open) == 0 || h.level > open[len(open)-1]:
+			if len(open) > 0 {
+				buf.WriteString("\n")
+			}
+			buf.WriteString("<ul>\n<li>")
+			open = append(open, h.level)
+		default:
+			// Close deeper lists, then the previous item.
+			for len(open) > 1 && h.level < open[len(open)-1] && h.level <= open[len(open)-2] {
+				buf.WriteString("</li>\n</ul>\n")
+				open = open[:len(open)-1]
+			}
+			buf.WriteString("</li>\n<li>")
+		}
+
+		fmt.Fprintf(&buf, `<a href="#%s">%s</a>`, html.EscapeString(h.id), html.EscapeString(h.text))
+	}
+
+	for range open {
+		buf.WriteString("</li>\n</ul>\n")
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseContent_TOC(t *testing.T) {
+	input := "# Design Doc\n\n## Goals\n\n### Non-goals\n\n#### Too deep\n\n## Goals\n\n## Über `code`\n"
+
+	result, err := parseContent([]byte(input), "", "", renderOptions{toc: true, tocDepth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expTOC := `<nav class="toc">
+<ul>
+<li><a href="#design-doc">Design Doc</a>
+<ul>
+<li><a href="#goals">Goals</a>
+<ul>
+<li><a href="#non-goals">Non-goals</a></li>
+</ul>
+</li>
+<li><a href="#goals-1">Goals</a></li>
+<li><a href="#über-code">Über code</a></li>
+</ul>
+</li>
+</ul>
+</nav>
+`
+	expHeadings := []string{
+		`<h1 id="design-doc">Design Doc</h1>`,
+		`<h2 id="goals">Goals</h2>`,
+		`<h3 id="non-goals">Non-goals</h3>`,
+		`<h4 id="too-deep">Too deep</h4>`,
+		`<h2 id="goals-1">Goals</h2>`,
+		`<h2 id="über-code">Über <code>code</code></h2>`,
+	}
+
+	for _, exp := range append([]string{expTOC}, expHeadings...) {
+		if !strings.Contains(string(result), exp) {
+			t.Errorf("Expected result to contain %q, got:\n%s", exp, result)
+		}
+	}
+}
+
+func TestParseContent_NoTOC(t *testing.T) {
+	result, err := parseContent([]byte("# Title\n"), "", "", renderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, unexp := range []string{"toc", "id="} {
+		if strings.Contains(string(result), unexp) {
+			t.Errorf("Expected result without %q, got:\n%s", unexp, result)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Magic bytes starting each supported compressed stream.
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicBzip2 = []byte("BZh")
+	magicZstd  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Magic bytes following the bzip2 header and its level, starting the
+// first block, or the end of the stream if it's empty.
+var (
+	magicBzip2Block = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+	magicBzip2End   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+)
+
+// bzip2HeaderLen is the length of the bzip2 magic bytes, level and block magic.
+const bzip2HeaderLen = 10
+
+// decompress returns a reader of the decompressed contents of r when it
+// starts with the magic bytes of a gzip, bzip2 or zstd stream, and of r
+// as is otherwise. Closing the returned reader releases the decompressor,
+// but doesn't close r.
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+
+	// Peek errors mean the input is shorter than the magic
+	// bytes, so it can't be compressed.
+	magic, _ := br.Peek(bzip2HeaderLen)
+
+	switch {
+	case bytes.HasPrefix(magic, magicGzip):
+		return gzip.NewReader(br)
+	case isBzip2(magic):
+		return io.NopCloser(bzip2.NewReader(br)), nil
+	case bytes.HasPrefix(magic, magicZstd):
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	}
+
+	return io.NopCloser(br), nil
+}
+
+// isBzip2 reports whether magic is a full bzip2 header: "BZh", a level
+// from 1 to 9 and the magic bytes of a block or of the end of the stream,
+// so text merely starting with "BZh" isn't taken for bzip2.
+func isBzip2(magic []byte) bool {
+	if len(magic) < bzip2HeaderLen || !bytes.HasPrefix(magic, magicBzip2) {
+		return false
+	}
+
+	level := magic[len(magicBzip2)]
+	rest := magic[len(magicBzip2)+1:]
+
+	return level >= '1' && level <= '9' &&
+		(bytes.Equal(rest, magicBzip2Block) || bytes.Equal(rest, magicBzip2End))
+}
+
+// readInput calls fn with the contents of r, transparently
+// decompressed unless raw is set.
+func readInput(r io.Reader, raw bool, fn func(r io.Reader) error) error {
+	if raw {
+		return fn(r)
+	}
+
+	dr, err := decompress(r)
+	if err != nil {
+		return err
+	}
+
+	if err = fn(dr); err != nil {
+		dr.Close()
+		return err
+	}
+
+	return dr.Close()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// gzipFile writes a gzip compressed copy of src into dir, the same
+// way walk archives files, and returns its name.
+func gzipFile(t *testing.T, src, dir string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Name = filepath.Base(src)
+	if _, err = zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fname := filepath.Join(dir, filepath.Base(src)+".gz")
+	if err = os.WriteFile(fname, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return fname
+}
+
+func TestRunCompressed(t *testing.T) {
+	gz := gzipFile(t, "testdata/testFile", t.TempDir())
+
+	testCases := []struct {
+		name  string
+		fname string
+	}{
+		{name: "Plain", fname: "testdata/testFile"},
+		{name: "Gzip", fname: gz},
+		{name: "Bzip2", fname: "testdata/testFile.bz2"},
+		{name: "Zstd", fname: "testdata/testFile.zst"},
+	}
+
+	// Counts over compressed files must match the original.
+	exp := "3\n16\n75\n75\n"
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			for _, cfg := range []config{{lines: true}, {}, {chars: charsRunes}, {bytes: true}} {
+				cfg.files = true
+				if err := run([]string{tc.fname}, nil, &out, cfg); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if out.String() != exp {
+				t.Errorf("Exp %q, got %q\n", exp, out.String())
+			}
+		})
+	}
+}
+
+func TestRunCompressedStdin(t *testing.T) {
+	data, err := os.ReadFile("testdata/testFile.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err = run(nil, bytes.NewReader(data), &out, config{}); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "16\n"
+	if out.String() != exp {
+		t.Errorf("Exp %q, got %q\n", exp, out.String())
+	}
+}
+
+func TestRunCompressedRaw(t *testing.T) {
+	info, err := os.Stat("testdata/testFile.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	cfg := config{files: true, bytes: true, raw: true}
+	if err = run([]string{"testdata/testFile.bz2"}, nil, &out, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := fmt.Sprintf("%d\n", info.Size())
+	if out.String() != exp {
+		t.Errorf("Exp %q, got %q\n", exp, out.String())
+	}
+}
+
+func TestRunCompressedCorrupt(t *testing.T) {
+	// A gzip header followed by garbage.
+	in := bytes.NewReader([]byte{0x1f, 0x8b, 0x08, 0x00, 0xde, 0xad, 0xbe, 0xef})
+
+	var out bytes.Buffer
+	if err := run(nil, in, &out, config{}); err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestExpandInputsCompressed(t *testing.T) {
+	dir := t.TempDir()
+	gz := gzipFile(t, "testdata/testFile", dir)
+
+	// Compressed text files aren't skipped as binary.
+	res, err := expandInputs([]string{dir}, config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0] != gz {
+		t.Errorf("Exp %q, got %q\n", []string{gz}, res)
+	}
+}
+
+func TestRunBzip2LikeText(t *testing.T) {
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "bz.txt")
+	if err := os.WriteFile(fname, []byte("BZh is how bzip2 streams start\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Text starting with the bzip2 magic bytes is counted as is.
+	var out bytes.Buffer
+	if err := run([]string{fname}, nil, &out, config{files: true}); err != nil {
+		t.Fatal(err)
+	}
+	if exp := "6\n"; out.String() != exp {
+		t.Errorf("Exp %q, got %q\n", exp, out.String())
+	}
+
+	// And isn't skipped as binary when walking directories.
+	res, err := expandInputs([]string{dir}, config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0] != fname {
+		t.Errorf("Exp %q, got %q\n", []string{fname}, res)
+	}
+}
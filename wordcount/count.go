@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+// counts holds every counter gathered from a single pass over an input.
+type counts struct {
+	lines     int
+	words     int
+	runes     int
+	graphemes int
+	bytes     int
+	records   int // tokens of a custom split, see newSplitter
+}
+
+// add accumulates the counters of o into c.
+func (c *counts) add(o counts) {
+	c.lines += o.lines
+	c.words += o.words
+	c.runes += o.runes
+	c.graphemes += o.graphemes
+	c.bytes += o.bytes
+	c.records += o.records
+}
+
+// chars returns the character counter selected by mode,
+// defaulting to runes.
+func (c counts) chars(mode string) int {
+	if mode == charsGraphemes {
+		return c.graphemes
+	}
+
+	return c.runes
+}
+
+// count returns the number of lines, words, characters and bytes given by io.Reader.
+// Words and user-perceived characters (grapheme clusters) follow the
+// Unicode text segmentation rules of UAX #29. Lines of any length are
+// counted.
+func count(r io.Reader) (counts, error) {
+	var c counter
+	err := scanLines(r, c.add)
+
+	return c.result(), err
+}
+
+// scanLines calls fn with every line read from r, split in chunks of at
+// most maxChunk bytes when they don't fit the scanner's buffer. joined
+// reports whether the chunk continues a word cut at the end of the
+// previous one. The chunk is only valid until fn returns.
+func scanLines(r io.Reader, fn func(chunk []byte, joined bool)) error {
+	// A scanner reads text from the reader, one line at a time,
+	// keeping the line endings so they're counted as bytes too.
+	var s lineSplitter
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), maxChunk)
+	scanner.Split(s.split)
+
+	joined := false
+	for scanner.Scan() {
+		fn(scanner.Bytes(), joined)
+		joined = s.cut
+	}
+
+	return scanner.Err()
+}
+
+// counter gathers counts from the consecutive chunks of an input
+// returned by scanLines.
+type counter struct {
+	counts
+	last byte // last byte added, to count a final line without a newline
+}
+
+// add adds the counters of a chunk to c.
+func (c *counter) add(chunk []byte, joined bool) {
+	if len(chunk) == 0 {
+		return
+	}
+
+	words := c.words
+	c.addChunk(chunk)
+
+	// The first word of a joined chunk was already counted.
+	if joined && c.words > words {
+		c.words--
+	}
+
+	c.last = chunk[len(chunk)-1]
+}
+
+// result returns the counters gathered so far, counting
+// the final line even if it has no newline.
+func (c *counter) result() counts {
+	res := c.counts
+	if res.bytes > 0 && c.last != '\n' {
+		res.lines++
+	}
+
+	return res
+}
+
+// addChunk adds the counters of a chunk of a line to c.
+// Only newlines are counted as lines.
+func (c *counts) addChunk(line []byte) {
+	if line[len(line)-1] == '\n' {
+		c.lines++
+	}
+	c.bytes += len(line)
+
+	// Most input is plain ASCII, which can be counted
+	// much faster than running the full segmentation rules.
+	if isASCII(line) {
+		c.runes += len(line)
+		c.graphemes += len(line)
+		if bytes.HasSuffix(line, []byte("\r\n")) {
+			c.graphemes--
+		}
+		c.words += asciiWords(line)
+		return
+	}
+
+	c.runes += utf8.RuneCount(line)
+
+	state := -1
+	for rest := line; len(rest) > 0; {
+		_, rest, _, state = uniseg.FirstGraphemeCluster(rest, state)
+		c.graphemes++
+	}
+
+	// A word is any segment holding a letter or a number,
+	// skipping the ones made of spaces and punctuation.
+	state = -1
+	for rest := line; len(rest) > 0; {
+		var segment []byte
+		segment, rest, state = uniseg.FirstWord(rest, state)
+		if isWord(segment) {
+			c.words++
+		}
+	}
+}
+
+// isWord reports whether the word segment holds a letter or a number.
+func isWord(segment []byte) bool {
+	for _, r := range string(segment) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isASCII reports whether b only holds ASCII characters.
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// asciiWords counts the words of an ASCII line, following the same
+// UAX #29 word boundary rules as uniseg.FirstWord. Letters and digits
+// are joined by underscores, two letters by a colon, a period or an
+// apostrophe, and two digits by a comma, a semicolon, a period or an
+// apostrophe.
+func asciiWords(line []byte) int {
+	words := 0
+	counted := false // the current segment was already counted as a word
+
+	for i := 0; i < len(line); i++ {
+		b := line[i]
+
+		if isASCIILetter(b) || isASCIIDigit(b) {
+			if !counted {
+				words++
+				counted = true
+			}
+			continue
+		}
+		if b == '_' {
+			continue
+		}
+
+		// Any other character ends the segment, unless it's a mid-word
+		// character between two letters or two digits.
+		if i > 0 && i+1 < len(line) {
+			prev, next := line[i-1], line[i+1]
+			switch b {
+			case ':':
+				if isASCIILetter(prev) && isASCIILetter(next) {
+					continue
+				}
+			case ',', ';':
+				if isASCIIDigit(prev) && isASCIIDigit(next) {
+					continue
+				}
+			case '.', '\'':
+				if isASCIILetter(prev) && isASCIILetter(next) ||
+					isASCIIDigit(prev) && isASCIIDigit(next) {
+					continue
+				}
+			}
+		}
+		counted = false
+	}
+
+	return words
+}
+
+func isASCIILetter(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+func isASCIIDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+// maxChunk is the size of the longest chunk returned by lineSplitter.
+const maxChunk = bufio.MaxScanTokenSize
+
+// lineSplitter splits input in lines like bufio.ScanLines, except the
+// returned lines keep their trailing newline and carriage return. Lines
+// that don't fit in maxChunk bytes are returned in chunks, so they never
+// exceed the scanner's buffer.
+type lineSplitter struct {
+	cut bool // last chunk was cut inside a word
+}
+
+// split is the bufio.SplitFunc of s.
+func (s *lineSplitter) split(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	s.cut = false
+
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	// Return the final line, which has no newline.
+	if atEOF {
+		return len(data), data, nil
+	}
+	if len(data) >= maxChunk {
+		var n int
+		n, s.cut = chunkEnd(data)
+		return n, data[:n], nil
+	}
+
+	// Request more data.
+	return 0, nil, nil
+}
+
+// chunkEnd returns where to cut a line chunk so words and grapheme
+// clusters stay whole: after its last ASCII space or punctuation, or else
+// before its last complete word. The last segments are kept for the next
+// chunk, as more data might still extend them. A chunk holding a single
+// word is cut between its grapheme clusters instead, reporting the word
+// was cut.
+func chunkEnd(data []byte) (n int, inWord bool) {
+	// Keep a following ASCII byte, which can't extend the break.
+	for i := len(data) - 2; i >= 0; i-- {
+		if isBreakByte(data[i]) && data[i+1] < utf8.RuneSelf {
+			return i + 1, false
+		}
+	}
+
+	words := segments(data, uniseg.FirstWord)
+	if len(words) > 2 && words[len(words)-3] > 0 {
+		return words[len(words)-3], false
+	}
+
+	clusters := segments(data, func(b []byte, state int) ([]byte, []byte, int) {
+		cluster, rest, _, state := uniseg.FirstGraphemeCluster(b, state)
+		return cluster, rest, state
+	})
+	if len(clusters) < 2 || clusters[len(clusters)-2] == 0 {
+		// A single grapheme cluster can't be split without changing the counters.
+		return len(data), false
+	}
+
+	// Find the word holding the cut, if it's not cut at its start.
+	n = clusters[len(clusters)-2]
+	for i := len(words) - 1; i >= 0; i-- {
+		if words[i] > n {
+			continue
+		}
+		if words[i] == n {
+			return n, false
+		}
+		end := len(data)
+		if i+1 < len(words) {
+			end = words[i+1]
+		}
+		return n, isWord(data[words[i]:end])
+	}
+
+	return n, false
+}
+
+// segments returns the offsets where the segments of data returned by
+// first start, first being uniseg.FirstWord or alike.
+func segments(data []byte, first func(b []byte, state int) ([]byte, []byte, int)) []int {
+	var offsets []int
+
+	state := -1
+	for off, rest := 0, data; len(rest) > 0; {
+		var segment []byte
+		segment, rest, state = first(rest, state)
+		offsets = append(offsets, off)
+		off += len(segment)
+	}
+
+	return offsets
+}
+
+// isBreakByte reports whether b is ASCII white space or punctuation
+// that can't join two parts of a word or a grapheme cluster.
+func isBreakByte(b byte) bool {
+	if b >= utf8.RuneSelf || isASCIILetter(b) || isASCIIDigit(b) {
+		return false
+	}
+
+	switch b {
+	case '_', '.', ':', ',', ';', '\'', '"', '\r':
+		return false
+	}
+
+	return true
+}
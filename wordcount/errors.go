@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidChars  = errors.New("invalid character mode")
+	ErrInvalidFormat = errors.New("invalid report format")
+	ErrSomeFailed    = errors.New("some inputs failed")
+	ErrAllFailed     = errors.New("all inputs failed")
+	ErrFollowInputs  = errors.New("follow mode takes a single input")
+	ErrInvalidPeriod = errors.New("invalid interval")
+	ErrInvalidSplit  = errors.New("invalid record split")
+)
+
+// inputsErr reports the inputs that couldn't be counted when
+// running with -k, while the others were.
+type inputsErr struct {
+	errs  []error
+	total int // number of inputs
+}
+
+// Implement the error interface.
+func (e *inputsErr) Error() string {
+	msgs := make([]string, 0, len(e.errs)+1)
+	for _, err := range e.errs {
+		msgs = append(msgs, err.Error())
+	}
+	msgs = append(msgs, fmt.Sprintf("%d of %d inputs failed", len(e.errs), e.total))
+
+	return strings.Join(msgs, "\n")
+}
+
+// Is allows errors.Is to tell if some or all inputs failed.
+func (e *inputsErr) Is(target error) bool {
+	if len(e.errs) == e.total {
+		return target == ErrAllFailed
+	}
+
+	return target == ErrSomeFailed
+}
+
+// inputsError returns an *inputsErr holding the non-nil errors of
+// every input, or nil if every input succeeded.
+func inputsError(errs []error) error {
+	e := &inputsErr{total: len(errs)}
+	for _, err := range errs {
+		if err != nil {
+			e.errs = append(e.errs, err)
+		}
+	}
+
+	if len(e.errs) == 0 {
+		return nil
+	}
+
+	return e
+}
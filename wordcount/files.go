@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sniffLen is how many bytes are read to decide if a file is binary,
+// the same amount git looks at.
+const sniffLen = 8000
+
+// expandInputs replaces every directory in args with the files found by
+// walking it, keeping the order of args. Walked files are filtered by the
+// include and exclude globs and the .gitignore files set in cfg, and
+// binary files are skipped. Other args are always kept, so the ones that
+// can't be read are reported when they're opened. So are the paths the
+// walk fails on with cfg.keepGoing, instead of ending it.
+func expandInputs(args []string, cfg config) ([]string, error) {
+	var filenames []string
+
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil || !info.IsDir() {
+			filenames = append(filenames, arg)
+			continue
+		}
+
+		files, err := walkDir(arg, cfg)
+		if err != nil {
+			return nil, err
+		}
+		filenames = append(filenames, files...)
+	}
+
+	return filenames, nil
+}
+
+// walkDir returns the text files under root that pass the filters in cfg.
+func walkDir(root string, cfg config) ([]string, error) {
+	var files []string
+
+	// Parsed .gitignore files, by the directory holding them.
+	ignores := make(map[string]*ignoreList)
+
+	// With keepGoing, the paths that fail are kept to be reported
+	// as failed when they're opened, and the walk goes on.
+	fail := func(path string, err error) error {
+		if !cfg.keepGoing {
+			return err
+		}
+		files = append(files, path)
+		return nil
+	}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fail(path, err)
+		}
+
+		if d.IsDir() {
+			if path != root && (d.Name() == ".git" || ignored(path, true, root, ignores) ||
+				matchGlobs(cfg.exclude, path, root)) {
+				return filepath.SkipDir
+			}
+
+			if cfg.gitignore {
+				l, err := parseIgnoreFile(path)
+				if err != nil {
+					return fail(filepath.Join(path, ".gitignore"), err)
+				}
+				ignores[path] = l
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() || ignored(path, false, root, ignores) {
+			return nil
+		}
+		if cfg.include != "" && !matchGlobs(cfg.include, path, root) {
+			return nil
+		}
+		if matchGlobs(cfg.exclude, path, root) {
+			return nil
+		}
+
+		binary, err := isBinaryFile(path, cfg.raw)
+		if err != nil {
+			return fail(path, err)
+		}
+		if binary {
+			return nil
+		}
+
+		files = append(files, path)
+		return nil
+	})
+
+	return files, err
+}
+
+// ignored reports whether path is ignored by the .gitignore files of its
+// parent directories, up to root. Deeper files take precedence.
+func ignored(path string, isDir bool, root string, ignores map[string]*ignoreList) bool {
+	// Collect the lists from the parent directory up to root.
+	var lists []*ignoreList
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if l := ignores[dir]; l != nil {
+			lists = append(lists, l)
+		}
+		if dir == root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	isIgnored := false
+	for i := len(lists) - 1; i >= 0; i-- {
+		if match, ignore := lists[i].match(path, isDir); match {
+			isIgnored = ignore
+		}
+	}
+
+	return isIgnored
+}
+
+// matchGlobs reports whether path matches any of the comma separated
+// globs. Globs holding a slash are matched against the path relative to
+// root, the others against the base name.
+func matchGlobs(globs, path, root string) bool {
+	if globs == "" {
+		return false
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, g := range strings.Split(globs, ",") {
+		name := filepath.Base(path)
+		if strings.Contains(g, "/") {
+			name = rel
+		}
+		if matchPath(g, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isBinaryFile reports whether the named file looks binary, that is it
+// holds a NUL byte within its first sniffLen bytes. Unless raw is set,
+// compressed files are sniffed after decompressing them, and the ones
+// that fail to decompress are reported as binary.
+func isBinaryFile(fname string, raw bool) (bool, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if !raw {
+		dr, err := decompress(f)
+		if err != nil {
+			return true, nil
+		}
+		defer dr.Close()
+		r = dr
+	}
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		if raw {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return bytes.IndexByte(buf[:n], 0) >= 0, nil
+}
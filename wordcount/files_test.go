@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// createTree creates the given files, relative to a temporary
+// directory, and returns the directory.
+func createTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, data := range files {
+		fname := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fname, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestExpandInputs(t *testing.T) {
+	dir := createTree(t, map[string]string{
+		".gitignore":          "*.log\nbuild/\n",
+		"main.go":             "package main\n",
+		"README.md":           "# Title\n",
+		"app.log":             "ignored\n",
+		"image.png":           "\x89PNG\r\n\x1a\n\x00\x00",
+		"build/out.txt":       "ignored\n",
+		"pkg/.gitignore":      "!keep.log\n",
+		"pkg/keep.log":        "kept\n",
+		"pkg/lib.go":          "package pkg\n",
+		"pkg/vendor/dep.go":   "package dep\n",
+		".git/HEAD":           "ref: refs/heads/main\n",
+		"docs/guide/intro.md": "intro\n",
+	})
+
+	testCases := []struct {
+		name string
+		cfg  config
+		exp  []string
+	}{
+		{name: "GitIgnore", cfg: config{gitignore: true},
+			exp: []string{".gitignore", "README.md", "docs/guide/intro.md", "main.go",
+				"pkg/.gitignore", "pkg/keep.log", "pkg/lib.go", "pkg/vendor/dep.go"}},
+		{name: "NoGitIgnore", cfg: config{},
+			exp: []string{".gitignore", "README.md", "app.log", "build/out.txt", "docs/guide/intro.md",
+				"main.go", "pkg/.gitignore", "pkg/keep.log", "pkg/lib.go", "pkg/vendor/dep.go"}},
+		{name: "Include", cfg: config{gitignore: true, include: "*.go,*.md"},
+			exp: []string{"README.md", "docs/guide/intro.md", "main.go", "pkg/lib.go", "pkg/vendor/dep.go"}},
+		{name: "Exclude", cfg: config{gitignore: true, include: "*.go", exclude: "vendor"},
+			exp: []string{"main.go", "pkg/lib.go"}},
+		{name: "IncludePath", cfg: config{gitignore: true, include: "docs/**"},
+			exp: []string{"docs/guide/intro.md"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := expandInputs([]string{dir}, tc.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			exp := make([]string, len(tc.exp))
+			for i, f := range tc.exp {
+				exp[i] = filepath.Join(dir, filepath.FromSlash(f))
+			}
+
+			if !reflect.DeepEqual(exp, res) {
+				t.Errorf("Exp %q, got %q\n", exp, res)
+			}
+		})
+	}
+}
+
+func TestExpandInputsKeepsFiles(t *testing.T) {
+	dir := createTree(t, map[string]string{"data.bin": "\x00\x01\x02"})
+	fname := filepath.Join(dir, "data.bin")
+
+	// Files given explicitly are never filtered out.
+	res, err := expandInputs([]string{fname}, config{gitignore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]string{fname}, res) {
+		t.Errorf("Exp %q, got %q\n", []string{fname}, res)
+	}
+}
+
+func TestRunByExtension(t *testing.T) {
+	dir := createTree(t, map[string]string{
+		"a.go":     "package a\n",
+		"b.go":     "package b\n\nfunc B() {}\n",
+		"notes.md": "# Notes\n",
+		"Makefile": "all:\n",
+	})
+
+	var out bytes.Buffer
+	if err := run([]string{dir}, nil, &out, config{files: true, byExt: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := " 1  1  5  5 (none)\n" +
+		" 4  6 33 33 .go\n" +
+		" 1  1  8  8 .md\n" +
+		" 6  8 46 46 total\n"
+	if out.String() != exp {
+		t.Errorf("Exp %q, got %q\n", exp, out.String())
+	}
+}
+
+func TestRunKeepGoingWalk(t *testing.T) {
+	dir := createTree(t, map[string]string{
+		"a.txt":                "one two\n",
+		"sub/b.txt":            "three\n",
+		"sub/.gitignore/c.txt": "four five\n",
+	})
+
+	// A .gitignore that can't be read ends the walk, unless keepGoing is set.
+	if _, err := expandInputs([]string{dir}, config{gitignore: true}); err == nil {
+		t.Fatal("Expected an error reading the .gitignore directory")
+	}
+
+	var out bytes.Buffer
+	cfg := config{files: true, gitignore: true, keepGoing: true, all: true}
+	err := run([]string{dir}, nil, &out, cfg)
+	if !errors.Is(err, ErrSomeFailed) {
+		t.Errorf("Expected error %q, got %q", ErrSomeFailed, err)
+	}
+
+	for _, exp := range []string{
+		filepath.Join(dir, "a.txt"),
+		filepath.Join(dir, "sub", "b.txt"),
+		filepath.Join(dir, "sub", ".gitignore", "c.txt"),
+	} {
+		if !strings.Contains(out.String(), exp) {
+			t.Errorf("Expected %s to be counted, got:\n%s", exp, out.String())
+		}
+	}
+}
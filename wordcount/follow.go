@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// pollInterval is how often a followed file is checked for new data.
+var pollInterval = 250 * time.Millisecond
+
+// jsonRates is the JSON representation of a follow mode report.
+type jsonRates struct {
+	jsonCounts
+	LinesPerSec float64 `json:"lines_per_sec"`
+	WordsPerSec float64 `json:"words_per_sec"`
+	BytesPerSec float64 `json:"bytes_per_sec"`
+}
+
+// runFollow follows the single file given, or the in reader if cfg.files
+// isn't set, until it's exhausted or the program gets SIGINT or SIGTERM.
+func runFollow(filenames []string, in io.Reader, out io.Writer, cfg config) error {
+	if cfg.interval <= 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidPeriod, cfg.interval)
+	}
+
+	stop := make(chan struct{})
+	sig := make(chan os.Signal, 1)
+
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	go func() {
+		<-sig
+		close(stop)
+	}()
+
+	if cfg.files {
+		if len(filenames) != 1 {
+			return fmt.Errorf("%w: got %d", ErrFollowInputs, len(filenames))
+		}
+
+		t, err := newTailFile(filenames[0], stop)
+		if err != nil {
+			return err
+		}
+		defer t.Close()
+		in = t
+	}
+
+	return follow(in, out, cfg, stop)
+}
+
+// follow counts r as it's read, writing the counters and their rates per
+// second to out every cfg.interval. It writes the final counters and
+// returns once r is exhausted or stop is closed.
+func follow(r io.Reader, out io.Writer, cfg config, stop <-chan struct{}) error {
+	var (
+		mu sync.Mutex
+		c  counter
+	)
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- scanLines(r, func(chunk []byte, joined bool) {
+			mu.Lock()
+			c.add(chunk, joined)
+			mu.Unlock()
+		})
+	}()
+
+	snapshot := func() counts {
+		mu.Lock()
+		defer mu.Unlock()
+		return c.result()
+	}
+
+	ticker := time.NewTicker(cfg.interval)
+	defer ticker.Stop()
+
+	prev, last := counts{}, time.Now()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			cur := snapshot()
+			if err := printRates(out, cur, prev, now.Sub(last), cfg); err != nil {
+				return err
+			}
+			prev, last = cur, now
+		case err := <-errCh:
+			if err != nil {
+				return err
+			}
+			return printRates(out, snapshot(), prev, time.Since(last), cfg)
+		case <-stop:
+			return printRates(out, snapshot(), prev, time.Since(last), cfg)
+		}
+	}
+}
+
+// printRates writes the counters in cur and how fast they grew
+// since prev, elapsed time ago.
+func printRates(out io.Writer, cur, prev counts, elapsed time.Duration, cfg config) error {
+	rate := func(cur, prev int) float64 {
+		if elapsed <= 0 {
+			return 0
+		}
+		return float64(cur-prev) / elapsed.Seconds()
+	}
+
+	lines, words, bytes := rate(cur.lines, prev.lines), rate(cur.words, prev.words),
+		rate(cur.bytes, prev.bytes)
+
+	if cfg.json {
+		return json.NewEncoder(out).Encode(jsonRates{
+			jsonCounts:  toJSONCounts(cur),
+			LinesPerSec: lines,
+			WordsPerSec: words,
+			BytesPerSec: bytes,
+		})
+	}
+
+	_, err := fmt.Fprintf(out, "%d lines (%.1f/s) %d words (%.1f/s) %d bytes (%.1f/s)\n",
+		cur.lines, lines, cur.words, words, cur.bytes, bytes)
+	return err
+}
+
+// tailFile reads a file as it grows, like tail -F. On EOF it polls the
+// file for new data, reading it again from the start when it's truncated
+// and reopening it when it's rotated. Reads return io.EOF only once stop
+// is closed.
+type tailFile struct {
+	name   string
+	f      *os.File
+	offset int64
+	stop   <-chan struct{}
+}
+
+// newTailFile opens the named file for following.
+func newTailFile(name string, stop <-chan struct{}) (*tailFile, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tailFile{name: name, f: f, stop: stop}, nil
+}
+
+// Read implements the io.Reader interface.
+func (t *tailFile) Read(p []byte) (int, error) {
+	for {
+		n, err := t.f.Read(p)
+		t.offset += int64(n)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+
+		// At EOF, read again right away if the file was swapped.
+		reset, err := t.reset()
+		if err != nil {
+			return 0, err
+		}
+		if reset {
+			continue
+		}
+
+		select {
+		case <-t.stop:
+			return 0, io.EOF
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// reset reopens the file if it was rotated, or rewinds it if it was
+// truncated, reporting whether it did either.
+func (t *tailFile) reset() (bool, error) {
+	info, err := os.Stat(t.name)
+	// While a rotated file isn't replaced yet, keep the old one.
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	cur, err := t.f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	if !os.SameFile(info, cur) {
+		f, err := os.Open(t.name)
+		if err != nil {
+			return false, err
+		}
+		t.f.Close()
+		t.f, t.offset = f, 0
+		return true, nil
+	}
+
+	if info.Size() < t.offset {
+		if _, err = t.f.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		t.offset = 0
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// Close closes the file being followed.
+func (t *tailFile) Close() error {
+	return t.f.Close()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFollow(t *testing.T) {
+	var out bytes.Buffer
+
+	r := strings.NewReader("word1 word2\nword3\n")
+	cfg := config{interval: time.Hour}
+	if err := follow(r, &out, cfg, make(chan struct{})); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the reader is exhausted, the final counters are written.
+	exp := regexp.MustCompile(`^2 lines \([0-9.]+/s\) 3 words \([0-9.]+/s\) 18 bytes \([0-9.]+/s\)\n$`)
+	if !exp.MatchString(out.String()) {
+		t.Errorf("Exp output matching %q, got %q\n", exp, out.String())
+	}
+}
+
+func TestFollowStop(t *testing.T) {
+	var out bytes.Buffer
+
+	// A reader that never returns, like an idle stdin.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	stop := make(chan struct{})
+	close(stop)
+
+	if err = follow(r, &out, config{interval: time.Hour}, stop); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "0 lines") {
+		t.Errorf("Exp final counters, got %q\n", out.String())
+	}
+}
+
+func TestPrintRates(t *testing.T) {
+	cur := counts{lines: 30, words: 120, runes: 600, graphemes: 600, bytes: 640}
+	prev := counts{lines: 10, words: 20, runes: 100, graphemes: 100, bytes: 140}
+
+	testCases := []struct {
+		name string
+		cfg  config
+		exp  string
+	}{
+		{name: "Text", cfg: config{},
+			exp: "30 lines (10.0/s) 120 words (50.0/s) 640 bytes (250.0/s)\n"},
+		{name: "JSON", cfg: config{json: true},
+			exp: `{"lines":30,"words":120,"runes":600,"graphemes":600,"bytes":640,` +
+				`"lines_per_sec":10,"words_per_sec":50,"bytes_per_sec":250}` + "\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			if err := printRates(&out, cur, prev, 2*time.Second, tc.cfg); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.exp {
+				t.Errorf("Exp %q, got %q\n", tc.exp, out.String())
+			}
+		})
+	}
+}
+
+func TestRunFollowInputs(t *testing.T) {
+	var out bytes.Buffer
+
+	cfg := config{files: true, follow: true, interval: time.Second}
+	err := run([]string{"testdata/testFile", "testdata/testFile2"}, nil, &out, cfg)
+	if !errors.Is(err, ErrFollowInputs) {
+		t.Errorf("Expected error %q, got %q", ErrFollowInputs, err)
+	}
+}
+
+func TestTailFile(t *testing.T) {
+	pollInterval = 5 * time.Millisecond
+
+	fname := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(fname, []byte("first\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	tf, err := newTailFile(fname, stop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tf.Close()
+
+	lines := make(chan string)
+	errCh := make(chan error)
+	go func() {
+		errCh <- scanLines(tf, func(line []byte, _ bool) {
+			lines <- string(line)
+		})
+	}()
+
+	expLine := func(exp string) {
+		t.Helper()
+		select {
+		case line := <-lines:
+			if line != exp {
+				t.Fatalf("Exp %q, got %q\n", exp, line)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %q\n", exp)
+		}
+	}
+
+	expLine("first\n")
+
+	// Appended data is read.
+	f, err := os.OpenFile(fname, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteString("appended\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	expLine("appended\n")
+
+	// A truncated file is read again from the start.
+	if err = os.WriteFile(fname, []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expLine("new\n")
+
+	// A rotated file is reopened.
+	if err = os.Rename(fname, fname+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(fname, []byte("rotated\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expLine("rotated\n")
+
+	close(stop)
+	if err = <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+)
+
+// Frequency report formats.
+const (
+	formatText = "text"
+	formatCSV  = "csv"
+	formatJSON = "json"
+)
+
+// freqConfig holds the options of the word frequency report.
+type freqConfig struct {
+	top       int    // number of words to report, 0 disables the report
+	fold      bool   // fold case so "Word" and "word" are counted together
+	strip     bool   // strip leading and trailing punctuation from words
+	stopwords string // file with words to leave out of the report
+	format    string // report format: text, csv or json
+}
+
+// wordFreq is a word with the number of times it appears.
+type wordFreq struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+// runFreq writes the cfg.freq.top most frequent words of the given files,
+// or the in reader if cfg.files isn't set, to out. With cfg.keepGoing
+// set, files that can't be read are left out and reported afterwards.
+// Words are split on white space, unless splitter isn't nil.
+func runFreq(filenames []string, in io.Reader, out io.Writer, cfg config, splitter func() bufio.SplitFunc) error {
+	if cfg.json {
+		cfg.freq.format = formatJSON
+	}
+
+	switch cfg.freq.format {
+	case formatText, formatCSV, formatJSON:
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidFormat, cfg.freq.format)
+	}
+
+	stop, err := loadStopwords(cfg.freq)
+	if err != nil {
+		return err
+	}
+
+	if splitter == nil {
+		splitter = func() bufio.SplitFunc { return bufio.ScanWords }
+	}
+
+	total := make(map[string]int)
+	var errs []error
+
+	if !cfg.files {
+		err = readInput(in, cfg.raw, func(r io.Reader) error {
+			var err error
+			total, err = frequencies(r, splitter(), cfg.freq, stop)
+			return err
+		})
+		if err != nil {
+			if !cfg.keepGoing {
+				return err
+			}
+			total = make(map[string]int)
+			errs = []error{err}
+		}
+	}
+
+	if cfg.files {
+		freqs := make([]map[string]int, len(filenames))
+
+		errs = forEachFile(filenames, cfg, func(i int, r io.Reader) error {
+			var err error
+			freqs[i], err = frequencies(r, splitter(), cfg.freq, stop)
+			return err
+		})
+
+		for i, freq := range freqs {
+			if errs[i] != nil {
+				if !cfg.keepGoing {
+					return errs[i]
+				}
+				continue
+			}
+			for w, n := range freq {
+				total[w] += n
+			}
+		}
+	}
+
+	if err = printFreq(out, topWords(total, cfg.freq.top), cfg.freq.format); err != nil {
+		return err
+	}
+
+	return inputsError(errs)
+}
+
+// frequencies returns how many times each word given by io.Reader
+// appears when split with split, leaving out the stop words.
+func frequencies(r io.Reader, split bufio.SplitFunc, cfg freqConfig, stop map[string]bool) (map[string]int, error) {
+	// A scanner reads text from the reader, split into words.
+	scanner := newTokenScanner(r, split)
+
+	fold := cases.Fold()
+	freq := make(map[string]int)
+
+	for scanner.Scan() {
+		w := normalizeWord(scanner.Text(), cfg, fold)
+		if w == "" || stop[w] {
+			continue
+		}
+		freq[w]++
+	}
+
+	return freq, scanner.Err()
+}
+
+// normalizeWord applies the punctuation stripping and
+// case folding options to the word.
+func normalizeWord(w string, cfg freqConfig, fold cases.Caser) string {
+	if cfg.strip {
+		w = strings.TrimFunc(w, unicode.IsPunct)
+	}
+	if cfg.fold {
+		w = fold.String(w)
+	}
+
+	return w
+}
+
+// loadStopwords reads the stopwords file set in cfg, if any. Words are
+// separated by white space, and lines starting with # are comments.
+// Stopwords are normalized like the words they're compared against.
+func loadStopwords(cfg freqConfig) (map[string]bool, error) {
+	stop := make(map[string]bool)
+
+	if cfg.stopwords == "" {
+		return stop, nil
+	}
+
+	f, err := os.Open(cfg.stopwords)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fold := cases.Fold()
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, w := range strings.Fields(line) {
+			stop[normalizeWord(w, cfg, fold)] = true
+		}
+	}
+
+	return stop, scanner.Err()
+}
+
+// topWords returns the n most frequent words, by descending count and
+// then alphabetically so the order is stable between runs.
+func topWords(freq map[string]int, n int) []wordFreq {
+	words := make([]wordFreq, 0, len(freq))
+	for w, c := range freq {
+		words = append(words, wordFreq{Word: w, Count: c})
+	}
+
+	sort.Slice(words, func(i, j int) bool {
+		if words[i].Count != words[j].Count {
+			return words[i].Count > words[j].Count
+		}
+		return words[i].Word < words[j].Word
+	})
+
+	if len(words) > n {
+		words = words[:n]
+	}
+
+	return words
+}
+
+// printFreq writes the words with their counts in the given format.
+func printFreq(out io.Writer, words []wordFreq, format string) error {
+	switch format {
+	case formatCSV:
+		w := csv.NewWriter(out)
+		w.Write([]string{"word", "count"})
+		for _, wf := range words {
+			w.Write([]string{wf.Word, strconv.Itoa(wf.Count)})
+		}
+		w.Flush()
+		return w.Error()
+	case formatJSON:
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(words)
+	}
+
+	// Words are sorted by count, so the first one sets the column width.
+	width := 0
+	if len(words) > 0 {
+		width = len(strconv.Itoa(words[0].Count))
+	}
+
+	for _, wf := range words {
+		if _, err := fmt.Fprintf(out, "%*d %s\n", width, wf.Count, wf.Word); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
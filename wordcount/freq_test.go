@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRunFreq(t *testing.T) {
+	const input = "The cat saw the dog. The dog saw a Cat!\nthe end"
+
+	testCases := []struct {
+		name string
+		freq freqConfig
+		exp  string
+	}{
+		{name: "Plain", freq: freqConfig{top: 3, format: formatText},
+			exp: "2 The\n2 saw\n2 the\n"},
+		{name: "FoldStrip", freq: freqConfig{top: 4, fold: true, strip: true, format: formatText},
+			exp: "4 the\n2 cat\n2 dog\n2 saw\n"},
+		{name: "Stopwords", freq: freqConfig{top: 2, fold: true, strip: true,
+			stopwords: "testdata/stopwords", format: formatText},
+			exp: "2 cat\n2 dog\n"},
+		{name: "CSV", freq: freqConfig{top: 2, fold: true, strip: true, format: formatCSV},
+			exp: "word,count\nthe,4\ncat,2\n"},
+		{name: "JSON", freq: freqConfig{top: 2, fold: true, strip: true, format: formatJSON},
+			exp: "[\n  {\n    \"word\": \"the\",\n    \"count\": 4\n  },\n" +
+				"  {\n    \"word\": \"cat\",\n    \"count\": 2\n  }\n]\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			if err := run(nil, bytes.NewBufferString(input), &out, config{freq: tc.freq}); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.exp {
+				t.Errorf("Exp %q, got %q\n", tc.exp, out.String())
+			}
+		})
+	}
+}
+
+func TestRunFreqFiles(t *testing.T) {
+	var out bytes.Buffer
+
+	cfg := config{files: true, freq: freqConfig{top: 2, fold: true, strip: true, format: formatText}}
+	if err := run([]string{"testdata/testFile", "testdata/testFile2"}, nil, &out, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "3 line\n2 a\n"
+	if out.String() != exp {
+		t.Errorf("Exp %q, got %q\n", exp, out.String())
+	}
+}
+
+func TestRunFreqInvalidFormat(t *testing.T) {
+	var out bytes.Buffer
+
+	cfg := config{freq: freqConfig{top: 1, format: "xml"}}
+	err := run(nil, bytes.NewBufferString("word"), &out, cfg)
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected error %q, got %q", ErrInvalidFormat, err)
+	}
+}
+
+func TestFrequenciesFoldUnicode(t *testing.T) {
+	freq, err := frequencies(bytes.NewBufferString("Straße STRASSE «straße»"), bufio.ScanWords,
+		freqConfig{fold: true, strip: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if freq["strasse"] != 3 {
+		t.Errorf("Exp 3 folded words, got %v\n", freq)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a single pattern read from a .gitignore file.
+type ignoreRule struct {
+	pattern  string // pattern without the leading ! and trailing /
+	negate   bool   // pattern started with !, re-including matching paths
+	dirOnly  bool   // pattern ended with /, matching directories only
+	anchored bool   // pattern holds a slash, matching relative to the .gitignore directory
+}
+
+// ignoreList holds the rules of a .gitignore file
+// and the directory they apply to.
+type ignoreList struct {
+	dir   string
+	rules []ignoreRule
+}
+
+// parseIgnoreFile reads the .gitignore file in dir. It returns nil
+// if the directory has no .gitignore file.
+func parseIgnoreFile(dir string) (*ignoreList, error) {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	l := &ignoreList{dir: dir}
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+
+		// Skip blank lines and comments.
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var r ignoreRule
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		}
+		// A leading backslash escapes a literal # or !.
+		line = strings.TrimPrefix(line, `\`)
+
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		r.pattern = line
+		l.rules = append(l.rules, r)
+	}
+
+	return l, scanner.Err()
+}
+
+// match reports whether any rule matches p and, if so, whether the last
+// matching rule ignores it or re-includes it.
+func (l *ignoreList) match(p string, isDir bool) (matched, ignore bool) {
+	rel, err := filepath.Rel(l.dir, p)
+	if err != nil {
+		return false, false
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, r := range l.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+
+		name := rel
+		if !r.anchored {
+			name = path.Base(rel)
+		}
+
+		if matchPath(r.pattern, name) {
+			matched = true
+			ignore = !r.negate
+		}
+	}
+
+	return matched, ignore
+}
+
+// matchPath reports whether the slash separated name matches pattern.
+// Every element of the pattern is matched with path.Match against an
+// element of name, except "**", which matches any number of elements.
+func matchPath(pattern, name string) bool {
+	return matchElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try matching the rest of the pattern at every depth.
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
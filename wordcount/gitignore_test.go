@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		name    string
+		exp     bool
+	}{
+		{pattern: "*.log", name: "app.log", exp: true},
+		{pattern: "*.log", name: "app.txt", exp: false},
+		{pattern: "doc/*.txt", name: "doc/notes.txt", exp: true},
+		{pattern: "doc/*.txt", name: "doc/sub/notes.txt", exp: false},
+		{pattern: "**/build", name: "build", exp: true},
+		{pattern: "**/build", name: "a/b/build", exp: true},
+		{pattern: "logs/**", name: "logs/a/b.log", exp: true},
+		{pattern: "a/**/z", name: "a/z", exp: true},
+		{pattern: "a/**/z", name: "a/b/c/z", exp: true},
+		{pattern: "a/**/z", name: "a/b/c/y", exp: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern+" "+tc.name, func(t *testing.T) {
+			if res := matchPath(tc.pattern, tc.name); res != tc.exp {
+				t.Errorf("Exp %t, got %t\n", tc.exp, res)
+			}
+		})
+	}
+}
+
+func TestIgnoreListMatch(t *testing.T) {
+	dir := t.TempDir()
+	rules := "# comment\n*.log\n!keep.log\nbuild/\n/root.txt\n\\#hash\n"
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := parseIgnoreFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		path       string
+		isDir      bool
+		expMatched bool
+		expIgnore  bool
+	}{
+		{path: "app.log", expMatched: true, expIgnore: true},
+		{path: "sub/app.log", expMatched: true, expIgnore: true},
+		{path: "keep.log", expMatched: true, expIgnore: false},
+		{path: "build", isDir: true, expMatched: true, expIgnore: true},
+		{path: "build", isDir: false, expMatched: false, expIgnore: false},
+		{path: "root.txt", expMatched: true, expIgnore: true},
+		{path: "sub/root.txt", expMatched: false, expIgnore: false},
+		{path: "#hash", expMatched: true, expIgnore: true},
+		{path: "main.go", expMatched: false, expIgnore: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			matched, ignore := l.match(filepath.Join(dir, tc.path), tc.isDir)
+			if matched != tc.expMatched || ignore != tc.expIgnore {
+				t.Errorf("Exp matched %t ignore %t, got %t %t\n",
+					tc.expMatched, tc.expIgnore, matched, ignore)
+			}
+		})
+	}
+}
+
+func TestParseIgnoreFileMissing(t *testing.T) {
+	l, err := parseIgnoreFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l != nil {
+		t.Errorf("Exp nil list, got %+v\n", l)
+	}
+}
@@ -1,3 +1,9 @@
 module github.com/adamwoolhether/cliApps/wordcount
 
 go 1.18
+
+require (
+	github.com/klauspost/compress v1.15.15
+	github.com/rivo/uniseg v0.4.7
+	golang.org/x/text v0.3.7
+)
@@ -1,58 +1,295 @@
+// Wordcount counts the lines, words, characters and bytes of its input.
+//
+// Exit codes:
+//
+//	0  every input was counted
+//	1  invalid options, or an input failed without -k
+//	2  some inputs failed with -k, the others were counted
+//	3  every input failed with -k
 package main
 
 import (
-	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+type config struct {
+	lines     bool          // count lines instead of words
+	bytes     bool          // count bytes instead of words
+	chars     string        // character counter to use: runes or graphemes
+	all       bool          // print every counter per input, wc style
+	byExt     bool          // print every counter per file extension
+	files     bool          // read from files instead of stdin
+	jobs      int           // number of files counted concurrently
+	include   string        // globs of files to count in directories
+	exclude   string        // globs of files and directories to skip in directories
+	gitignore bool          // honor .gitignore files in directories
+	raw       bool          // count compressed input as is, without decompressing it
+	keepGoing bool          // report unreadable inputs and keep counting the others
+	json      bool          // print the report as JSON
+	follow    bool          // follow a growing input, printing counters periodically
+	interval  time.Duration // how often counters are printed in follow mode
+	delim     string        // count records ending with this delimiter
+	pattern   string        // count tokens matching this regular expression
+	freq      freqConfig    // word frequency report options
+}
+
+// Exit codes, also listed in the usage message.
+const (
+	exitOK         = 0 // every input was counted
+	exitError      = 1 // invalid options, or an input failed without -k
+	exitSomeFailed = 2 // some inputs failed with -k, the others were counted
+	exitAllFailed  = 3 // every input failed with -k
+)
+
+// Character counting modes.
+const (
+	charsRunes     = "runes"
+	charsGraphemes = "graphemes"
 )
 
 func main() {
 	// Define a boolean flag -l to count lines instead of words.
 	lineCount := flag.Bool("l", false, "Count lines")
 	byteCount := flag.Bool("b", false, "Count bytes")
-	file := flag.Bool("f", false, "Read from file(s) instead of stdin")
-	// Parse the given flags.
-	flag.Parse()
-
-	if *file {
-		num := 0
-		for i := range flag.Args() {
-			fileData, err := os.Open(flag.Arg(i))
-			if err != nil {
-				fmt.Fprintln(os.Stderr, err)
-				os.Exit(1)
-			}
-			num += count(fileData, *lineCount, *byteCount)
+	chars := flag.String("chars", "", "Count characters as runes or graphemes (user-perceived characters)")
+	all := flag.Bool("a", false, "Print lines, words, characters and bytes for each input")
+	jsonOut := flag.Bool("json", false, "Print counters and errors of each input as JSON")
+	keepGoing := flag.Bool("k", false, "Keep counting past inputs that can't be read")
+	byExt := flag.Bool("ext", false, "Print lines, words, characters and bytes for each file extension")
+	file := flag.Bool("f", false, "Read from file(s) or directories instead of stdin")
+	jobs := flag.Int("j", runtime.NumCPU(), "Number of files to count concurrently")
+	// Follow options
+	followInput := flag.Bool("follow", false, "Follow a growing file or stdin, printing counters and rates periodically")
+	interval := flag.Duration("interval", time.Second, "How often counters are printed with -follow")
+	// Directory options
+	include := flag.String("include", "", "Comma separated globs of files to count in directories")
+	exclude := flag.String("exclude", "", "Comma separated globs of files and directories to skip in directories")
+	gitignore := flag.Bool("gitignore", true, "Honor .gitignore files in directories")
+	raw := flag.Bool("raw", false, "Count gzip, bzip2 and zstd compressed input as is, without decompressing it")
+	// Record options
+	delim := flag.String("d", "", `Count records ending with this delimiter instead of words, e.g. ";" or "\0"`)
+	pattern := flag.String("re", "", "Count tokens matching this regular expression instead of words")
+	// Word frequency options
+	top := flag.Int("top", 0, "Report the N most frequent words instead of counting")
+	fold := flag.Bool("fold", false, "Fold case of words in the frequency report")
+	strip := flag.Bool("strip", false, "Strip leading and trailing punctuation from words in the frequency report")
+	stop := flag.String("stop", "", "File with stopwords to leave out of the frequency report")
+	format := flag.String("format", formatText, "Frequency report format: text, csv or json")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\nExit codes:\n"+
+			"  %d  every input was counted\n"+
+			"  %d  invalid options, or an input failed without -k\n"+
+			"  %d  some inputs failed with -k, the others were counted\n"+
+			"  %d  every input failed with -k\n",
+			exitOK, exitError, exitSomeFailed, exitAllFailed)
+	}
+	// Parse the given flags. Invalid ones exit with exitError rather than
+	// the flag package's 2, which reports inputs that failed with -k.
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitError)
+	}
+
+	c := config{
+		lines:     *lineCount,
+		bytes:     *byteCount,
+		chars:     *chars,
+		all:       *all,
+		byExt:     *byExt,
+		files:     *file,
+		jobs:      *jobs,
+		include:   *include,
+		exclude:   *exclude,
+		gitignore: *gitignore,
+		raw:       *raw,
+		keepGoing: *keepGoing,
+		json:      *jsonOut,
+		follow:    *followInput,
+		interval:  *interval,
+		delim:     *delim,
+		pattern:   *pattern,
+		freq: freqConfig{
+			top:       *top,
+			fold:      *fold,
+			strip:     *strip,
+			stopwords: *stop,
+			format:    *format,
+		},
+	}
+
+	if err := run(flag.Args(), os.Stdin, os.Stdout, c); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// exitCode maps the error returned by run to the exit code of the program.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, ErrAllFailed):
+		return exitAllFailed
+	case errors.Is(err, ErrSomeFailed):
+		return exitSomeFailed
+	}
+
+	return exitError
+}
+
+// run counts the given files, or the in reader if cfg.files isn't set,
+// and writes the report to out. Directories are walked recursively.
+func run(filenames []string, in io.Reader, out io.Writer, cfg config) error {
+	var results []result
+
+	switch cfg.chars {
+	case "", charsRunes, charsGraphemes:
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidChars, cfg.chars)
+	}
+
+	if cfg.follow {
+		return runFollow(filenames, in, out, cfg)
+	}
+
+	if cfg.files {
+		var err error
+		if filenames, err = expandInputs(filenames, cfg); err != nil {
+			return err
+		}
+	}
+
+	splitter, err := newSplitter(cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.freq.top > 0 {
+		return runFreq(filenames, in, out, cfg, splitter)
+	}
+
+	// With a custom split, only records are counted.
+	countInput := count
+	if splitter != nil {
+		countInput = func(r io.Reader) (counts, error) {
+			n, err := countRecords(r, splitter())
+			return counts{records: n}, err
+		}
+	}
+
+	if !cfg.files {
+		results = make([]result, 1)
+
+		results[0].err = readInput(in, cfg.raw, func(r io.Reader) error {
+			var err error
+			results[0].counts, err = countInput(r)
+			return err
+		})
+	}
+
+	if cfg.files {
+		results = make([]result, len(filenames))
+
+		errs := forEachFile(filenames, cfg, func(i int, r io.Reader) error {
+			var err error
+			results[i].counts, err = countInput(r)
+			return err
+		})
+		for i := range results {
+			results[i].name = filenames[i]
+			results[i].err = errs[i]
+		}
+	}
+
+	// Partial counts of failed inputs are discarded.
+	errs := make([]error, len(results))
+	for i := range results {
+		if results[i].err == nil {
+			continue
 		}
-		fmt.Println(num)
-		return
+		if !cfg.keepGoing {
+			return results[i].err
+		}
+		results[i].counts = counts{}
+		errs[i] = results[i].err
+	}
+
+	if err := printResults(out, results, cfg); err != nil {
+		return err
 	}
 
-	fmt.Println(count(os.Stdin, *lineCount, *byteCount))
+	return inputsError(errs)
 }
 
-// count returns the number of words given by io.Reader.
-func count(r io.Reader, countLines, countBytes bool) int {
-	// A scanner reads text from the reader.
-	scanner := bufio.NewScanner(r)
+// forEachFile opens every given file and calls fn with its index and
+// contents, using a bounded pool of cfg.jobs workers so only a handful of
+// files are open at any time. Each index is handled by a single worker,
+// so fn can store per-file results without locking. The error of every
+// file is returned, in input order.
+func forEachFile(filenames []string, cfg config, fn func(i int, r io.Reader) error) []error {
+	errs := make([]error, len(filenames))
 
-	// If count lines flag isn't set, we count the words.
-	if !countLines {
-		scanner.Split(bufio.ScanWords)
+	jobs := cfg.jobs
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
 	}
-	if countBytes {
-		scanner.Split(bufio.ScanBytes)
+
+	// Indexes of the files to process. This is our worker-queue.
+	filesCh := make(chan int)
+
+	// Loop through all files, sending them to our queue channel for
+	// processing when a worker is available.
+	go func() {
+		defer close(filesCh)
+		for i := range filenames {
+			filesCh <- i
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range filesCh {
+				errs[i] = processFile(filenames[i], cfg.raw, func(r io.Reader) error {
+					return fn(i, r)
+				})
+			}
+		}()
 	}
 
-	// Define a counter.
-	wc := 0
+	wg.Wait()
+
+	return errs
+}
+
+// processFile opens the named file, calls fn with its contents,
+// decompressed unless raw is set, and closes it again.
+func processFile(fname string, raw bool, fn func(r io.Reader) error) error {
+	f, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
 
-	// Increment the counter for every words scanned.
-	for scanner.Scan() {
-		wc++
+	if err = readInput(f, raw, fn); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", fname, err)
 	}
 
-	return wc
+	return f.Close()
 }
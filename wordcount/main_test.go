@@ -2,7 +2,17 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
+
+	"github.com/rivo/uniseg"
 )
 
 // TestCountWords tests the count function set to count words.
@@ -11,9 +21,12 @@ func TestCountWords(t *testing.T) {
 
 	exp := 4
 
-	res := count(b, false, false)
-	if res != exp {
-		t.Errorf("Exp %d, got %d.\n", exp, res)
+	res, err := count(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.words != exp {
+		t.Errorf("Exp %d, got %d.\n", exp, res.words)
 	}
 }
 
@@ -23,9 +36,12 @@ func TestCountLines(t *testing.T) {
 
 	exp := 3
 
-	res := count(b, true, false)
-	if res != exp {
-		t.Errorf("Exp %d, got %d\n", exp, res)
+	res, err := count(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.lines != exp {
+		t.Errorf("Exp %d, got %d\n", exp, res.lines)
 	}
 }
 
@@ -35,8 +51,380 @@ func TestCountBytes(t *testing.T) {
 
 	exp := 24
 
-	res := count(b, false, true)
+	res, err := count(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.bytes != exp {
+		t.Errorf("Exp %d, got %d\n", exp, res.bytes)
+	}
+}
+
+// TestCountAll tests that a single pass gathers every counter.
+func TestCountAll(t *testing.T) {
+	b := bytes.NewBufferString("héllo  wörld\n\tline two\n")
+
+	exp := counts{lines: 2, words: 4, runes: 23, graphemes: 23, bytes: 25}
+
+	res, err := count(b)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if res != exp {
-		t.Errorf("Exp %d, got %d\n", exp, res)
+		t.Errorf("Exp %+v, got %+v\n", exp, res)
+	}
+}
+
+// TestCountUnicode tests rune, grapheme and word counting on non-ASCII text.
+func TestCountUnicode(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		exp   counts
+	}{
+		{name: "Combining", input: "cafe\u0301 noe\u0308l",
+			exp: counts{lines: 1, words: 2, runes: 11, graphemes: 9, bytes: 13}},
+		{name: "Emoji", input: "thumbs 👍🏽 family 👨‍👩‍👧",
+			exp: counts{lines: 1, words: 2, runes: 22, graphemes: 17, bytes: 41}},
+		{name: "CJK", input: "日本語のテキスト",
+			exp: counts{lines: 1, words: 5, runes: 8, graphemes: 8, bytes: 24}},
+		{name: "Punctuation", input: "Hello, world... it's well-known (really)!",
+			exp: counts{lines: 1, words: 6, runes: 41, graphemes: 41, bytes: 41}},
+		{name: "CRLF", input: "one two\r\nthree\r\n",
+			exp: counts{lines: 2, words: 3, runes: 16, graphemes: 14, bytes: 16}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := count(bytes.NewBufferString(tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != tc.exp {
+				t.Errorf("Exp %+v, got %+v\n", tc.exp, res)
+			}
+		})
+	}
+}
+
+// TestCountLongLines tests counting single lines of several megabytes,
+// longer than the scanner's buffer, against counting them in one go.
+func TestCountLongLines(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{name: "Words", input: strings.Repeat("word ", 1<<20)},
+		{name: "SingleWord", input: strings.Repeat("a", 3<<20)},
+		{name: "MinifiedJSON", input: strings.Repeat(`{"key":"value","n":12.5},`, 1<<17)},
+		{name: "Unicode", input: strings.Repeat("héllo wörld ", 1<<16)},
+		{name: "CJK", input: strings.Repeat("日本語のテキスト", 1<<15)},
+		{name: "CombiningWord", input: strings.Repeat("e\u0301", 1<<18)},
+		{name: "Newline", input: strings.Repeat("word ", 1<<20) + "\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var exp counts
+			exp.addChunk([]byte(tc.input))
+			exp.lines = 1
+
+			res, err := count(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != exp {
+				t.Errorf("Exp %+v, got %+v\n", exp, res)
+			}
+		})
+	}
+}
+
+// TestRunLongRecords tests counting records, regular expression matches
+// and word frequencies in records and lines of several megabytes, longer
+// than the scanner's default buffer.
+func TestRunLongRecords(t *testing.T) {
+	record := strings.Repeat("x", 100<<10)
+	json := strings.Repeat(`{"key":"value","n":12.5},`, 1<<17)
+
+	testCases := []struct {
+		name  string
+		input string
+		cfg   config
+		exp   string
+	}{
+		{name: "Delimiter", input: record + ";" + record + ";" + record + ";",
+			cfg: config{delim: ";"}, exp: "3\n"},
+		{name: "Regexp", input: json, cfg: config{pattern: `"key"`}, exp: fmt.Sprintf("%d\n", 1<<17)},
+		{name: "Top", input: record + ";" + record + ";" + record + ";",
+			cfg: config{delim: ";", freq: freqConfig{top: 1, format: formatText}}, exp: "3 " + record + "\n"},
+		{name: "TopWords", input: strings.Repeat("a", 3<<20) + " b",
+			cfg: config{freq: freqConfig{top: 1, format: formatText}}, exp: "1 " + strings.Repeat("a", 3<<20) + "\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := run(nil, strings.NewReader(tc.input), &out, tc.cfg); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.exp {
+				t.Errorf("Exp %.40q, got %.40q\n", tc.exp, out.String())
+			}
+		})
+	}
+}
+
+// TestCountReadError tests that read errors are reported
+// instead of returning partial counts.
+func TestCountReadError(t *testing.T) {
+	errRead := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("word1 word2\nword3"), iotest.ErrReader(errRead))
+
+	if _, err := count(r); !errors.Is(err, errRead) {
+		t.Errorf("Exp error %q, got %q\n", errRead, err)
+	}
+}
+
+// TestCountASCIIWords tests that the ASCII fast path splits words
+// the same way as the Unicode segmentation rules.
+func TestCountASCIIWords(t *testing.T) {
+	alphabet := []byte("aZ09_ .,;:'\"\t\r\n-")
+	rnd := rand.New(rand.NewSource(1))
+
+	for n := 0; n < 10000; n++ {
+		line := make([]byte, rnd.Intn(16))
+		for i := range line {
+			line[i] = alphabet[rnd.Intn(len(alphabet))]
+		}
+
+		exp := 0
+		state := -1
+		for rest := line; len(rest) > 0; {
+			var segment []byte
+			segment, rest, state = uniseg.FirstWord(rest, state)
+			if isWord(segment) {
+				exp++
+			}
+		}
+
+		if res := asciiWords(line); res != exp {
+			t.Fatalf("%q: Exp %d, got %d\n", line, exp, res)
+		}
+	}
+}
+
+// TestRun tests the output of run for the different report modes.
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files []string
+		stdin string
+		cfg   config
+		exp   string
+	}{
+		{name: "StdinWords", stdin: "word1 word2\nword3\n", cfg: config{}, exp: "3\n"},
+		{name: "StdinLines", stdin: "word1 word2\nword3\n", cfg: config{lines: true}, exp: "2\n"},
+		{name: "FilesWords", files: []string{"testdata/testFile", "testdata/testFile2"},
+			cfg: config{files: true}, exp: "23\n"},
+		{name: "FilesBytes", files: []string{"testdata/testFile", "testdata/testFile2"},
+			cfg: config{files: true, bytes: true}, exp: "113\n"},
+		{name: "StdinGraphemes", stdin: "noe\u0308l\n", cfg: config{chars: charsGraphemes}, exp: "5\n"},
+		{name: "StdinRunes", stdin: "noe\u0308l\n", cfg: config{chars: charsRunes}, exp: "6\n"},
+		{name: "StdinAll", stdin: "word1 word2\nword3\n", cfg: config{all: true},
+			exp: " 2  3 18 18\n"},
+		{name: "FilesAll", files: []string{"testdata/testFile", "testdata/testFile2"},
+			cfg: config{files: true, all: true},
+			exp: "  3  16  75  75 testdata/testFile\n" +
+				"  2   7  38  38 testdata/testFile2\n" +
+				"  5  23 113 113 total\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			if err := run(tc.files, bytes.NewBufferString(tc.stdin), &out, tc.cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != tc.exp {
+				t.Errorf("Exp %q, got %q\n", tc.exp, out.String())
+			}
+		})
+	}
+}
+
+// TestRunInvalidChars tests that run rejects unknown character modes.
+func TestRunInvalidChars(t *testing.T) {
+	var out bytes.Buffer
+
+	err := run(nil, bytes.NewBufferString("word"), &out, config{chars: "bytes"})
+	if !errors.Is(err, ErrInvalidChars) {
+		t.Errorf("Expected error %q, got %q", ErrInvalidChars, err)
+	}
+}
+
+// TestRunMissingFile tests that run reports files that can't be opened.
+func TestRunMissingFile(t *testing.T) {
+	var out bytes.Buffer
+
+	err := run([]string{"testdata/testFile", "testdata/missing"}, nil, &out, config{files: true})
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+}
+
+// TestRunJobs tests that results keep the input order
+// regardless of the number of workers.
+func TestRunJobs(t *testing.T) {
+	files := []string{"testdata/testFile2", "testdata/testFile", "testdata/testFile2"}
+	exp := "  2   7  38  38 testdata/testFile2\n" +
+		"  3  16  75  75 testdata/testFile\n" +
+		"  2   7  38  38 testdata/testFile2\n" +
+		"  7  30 151 151 total\n"
+
+	for _, jobs := range []int{1, 2, 8} {
+		t.Run(fmt.Sprintf("Jobs%d", jobs), func(t *testing.T) {
+			var out bytes.Buffer
+
+			if err := run(files, nil, &out, config{files: true, all: true, jobs: jobs}); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != exp {
+				t.Errorf("Exp %q, got %q\n", exp, out.String())
+			}
+		})
+	}
+}
+
+/*
+// To compare sequential and concurrent counting:
+go test -bench . -benchtime=10x -run ^$ -benchmem
+*/
+func BenchmarkRun(b *testing.B) {
+	// Generate a set of log-like files to count.
+	dir := b.TempDir()
+	line := strings.Repeat("lorem ipsum dolor sit amet, consectetur adipiscing elit ", 4) + "\n"
+	data := []byte(strings.Repeat(line, 500))
+
+	filenames := make([]string, 200)
+	for i := range filenames {
+		filenames[i] = filepath.Join(dir, fmt.Sprintf("file%03d.log", i))
+		if err := os.WriteFile(filenames[i], data, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for _, jobs := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("Jobs%d", jobs), func(b *testing.B) {
+			cfg := config{files: true, all: true, jobs: jobs}
+			for i := 0; i < b.N; i++ {
+				if err := run(filenames, nil, io.Discard, cfg); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	}
+}
+
+// TestRunKeepGoing tests that with keepGoing set, failed files are
+// reported and the others are still counted.
+func TestRunKeepGoing(t *testing.T) {
+	testCases := []struct {
+		name   string
+		files  []string
+		cfg    config
+		exp    string
+		expErr error
+	}{
+		{name: "SomeFailed", files: []string{"testdata/testFile", "testdata/missing"},
+			cfg: config{all: true}, exp: " 3 16 75 75 testdata/testFile\n", expErr: ErrSomeFailed},
+		{name: "AllFailed", files: []string{"testdata/missing", "testdata/missing2"},
+			cfg: config{}, exp: "0\n", expErr: ErrAllFailed},
+		{name: "NoneFailed", files: []string{"testdata/testFile"},
+			cfg: config{}, exp: "16\n", expErr: nil},
+		{name: "FreqSomeFailed", files: []string{"testdata/testFile2", "testdata/missing"},
+			cfg: config{freq: freqConfig{top: 1, format: formatText}}, exp: "1 2.\n", expErr: ErrSomeFailed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			tc.cfg.files = true
+			tc.cfg.keepGoing = true
+			err := run(tc.files, nil, &out, tc.cfg)
+			if tc.expErr == nil && err != nil {
+				t.Fatalf("Unexpected error: %q", err)
+			}
+			if !errors.Is(err, tc.expErr) {
+				t.Errorf("Expected error %q, got %q", tc.expErr, err)
+			}
+			if out.String() != tc.exp {
+				t.Errorf("Exp %q, got %q\n", tc.exp, out.String())
+			}
+		})
+	}
+}
+
+// TestRunJSON tests the JSON report, including per-file errors.
+func TestRunJSON(t *testing.T) {
+	var out bytes.Buffer
+
+	cfg := config{files: true, json: true, keepGoing: true}
+	err := run([]string{"testdata/testFile2", "testdata/missing"}, nil, &out, cfg)
+	if !errors.Is(err, ErrSomeFailed) {
+		t.Errorf("Expected error %q, got %q", ErrSomeFailed, err)
+	}
+
+	exp := `{
+  "files": [
+    {
+      "name": "testdata/testFile2",
+      "lines": 2,
+      "words": 7,
+      "runes": 38,
+      "graphemes": 38,
+      "bytes": 38
+    },
+    {
+      "name": "testdata/missing",
+      "error": "open testdata/missing: no such file or directory"
+    }
+  ],
+  "total": {
+    "lines": 2,
+    "words": 7,
+    "runes": 38,
+    "graphemes": 38,
+    "bytes": 38
+  },
+  "failed": 1
+}
+`
+	if out.String() != exp {
+		t.Errorf("Exp %s, got %s\n", exp, out.String())
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		exp  int
+	}{
+		{name: "OK", err: nil, exp: exitOK},
+		{name: "Error", err: ErrInvalidChars, exp: exitError},
+		{name: "SomeFailed", err: inputsError([]error{nil, os.ErrNotExist}), exp: exitSomeFailed},
+		{name: "AllFailed", err: inputsError([]error{os.ErrNotExist}), exp: exitAllFailed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if res := exitCode(tc.err); res != tc.exp {
+				t.Errorf("Exp %d, got %d\n", tc.exp, res)
+			}
+		})
 	}
 }
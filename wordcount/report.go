@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// noExt names the group of files without an extension.
+const noExt = "(none)"
+
+// result holds the counters of a single input, or the error reading it.
+// An empty name means the input was read from stdin.
+type result struct {
+	name   string
+	counts counts
+	err    error
+}
+
+// jsonCounts is the JSON representation of counts.
+type jsonCounts struct {
+	Lines     int `json:"lines"`
+	Words     int `json:"words"`
+	Runes     int `json:"runes"`
+	Graphemes int `json:"graphemes"`
+	Bytes     int `json:"bytes"`
+	Records   int `json:"records,omitempty"`
+}
+
+// jsonResult is the JSON representation of a result. Counters
+// are left out when the input failed.
+type jsonResult struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+	*jsonCounts
+}
+
+// jsonReport is the JSON document printed with -json.
+type jsonReport struct {
+	Files      []jsonResult `json:"files"`
+	Extensions []jsonResult `json:"extensions,omitempty"`
+	Total      jsonCounts   `json:"total"`
+	Failed     int          `json:"failed"`
+}
+
+// printResults writes the report selected by cfg. Failed
+// results are left out of the text reports.
+func printResults(out io.Writer, results []result, cfg config) error {
+	if cfg.json {
+		return printJSON(out, results, cfg)
+	}
+
+	ok := succeeded(results)
+
+	if cfg.byExt {
+		return printTable(out, byExtension(ok), cfg)
+	}
+	if cfg.all {
+		return printTable(out, ok, cfg)
+	}
+
+	return printTotal(out, ok, cfg)
+}
+
+// printJSON writes the counters or the error of every result, their
+// totals, and the totals by extension if cfg.byExt is set, as JSON.
+func printJSON(out io.Writer, results []result, cfg config) error {
+	rep := jsonReport{
+		Files: make([]jsonResult, 0, len(results)),
+		Total: toJSONCounts(total(results)),
+	}
+
+	for _, r := range results {
+		jr := jsonResult{Name: r.name}
+		if r.err != nil {
+			jr.Error = r.err.Error()
+			rep.Failed++
+		} else {
+			c := toJSONCounts(r.counts)
+			jr.jsonCounts = &c
+		}
+		rep.Files = append(rep.Files, jr)
+	}
+
+	if cfg.byExt {
+		for _, r := range byExtension(succeeded(results)) {
+			c := toJSONCounts(r.counts)
+			rep.Extensions = append(rep.Extensions, jsonResult{Name: r.name, jsonCounts: &c})
+		}
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+func toJSONCounts(c counts) jsonCounts {
+	return jsonCounts{
+		Lines:     c.lines,
+		Words:     c.words,
+		Runes:     c.runes,
+		Graphemes: c.graphemes,
+		Bytes:     c.bytes,
+		Records:   c.records,
+	}
+}
+
+// succeeded returns the results that didn't fail.
+func succeeded(results []result) []result {
+	var ok []result
+	for _, r := range results {
+		if r.err == nil {
+			ok = append(ok, r)
+		}
+	}
+
+	return ok
+}
+
+// total sums the counters of all results.
+func total(results []result) counts {
+	var t counts
+	for _, r := range results {
+		t.add(r.counts)
+	}
+
+	return t
+}
+
+// byExtension sums the counters of the results by file extension,
+// sorted by extension. Files without an extension are grouped together.
+func byExtension(results []result) []result {
+	var exts []result
+	idx := make(map[string]int)
+
+	for _, r := range results {
+		ext := filepath.Ext(r.name)
+		if ext == "" {
+			ext = noExt
+		}
+
+		i, ok := idx[ext]
+		if !ok {
+			i = len(exts)
+			idx[ext] = i
+			exts = append(exts, result{name: ext})
+		}
+		exts[i].counts.add(r.counts)
+	}
+
+	sort.Slice(exts, func(i, j int) bool {
+		return exts[i].name < exts[j].name
+	})
+
+	return exts
+}
+
+// printTotal writes the single counter selected by cfg,
+// summed across all results.
+func printTotal(out io.Writer, results []result, cfg config) error {
+	t := total(results)
+
+	n := t.words
+	switch {
+	case cfg.delim != "" || cfg.pattern != "":
+		n = t.records
+	case cfg.bytes:
+		n = t.bytes
+	case cfg.lines:
+		n = t.lines
+	case cfg.chars != "":
+		n = t.chars(cfg.chars)
+	}
+
+	_, err := fmt.Fprintln(out, n)
+	return err
+}
+
+// printTable writes lines, words, characters and bytes for each result
+// in right-aligned columns, followed by a total row when there's more
+// than one result, like coreutils wc. The characters column shows the
+// counter selected by cfg.chars. With a custom split, only records are
+// written.
+func printTable(out io.Writer, results []result, cfg config) error {
+	t := total(results)
+	records := cfg.delim != "" || cfg.pattern != ""
+
+	// The byte count is never smaller than the other counters, so it sets the column width.
+	width := len(strconv.Itoa(t.bytes))
+	if records {
+		width = len(strconv.Itoa(t.records))
+	}
+
+	rows := results
+	if len(results) > 1 {
+		rows = append(rows[:len(rows):len(rows)], result{name: "total", counts: t})
+	}
+
+	for _, r := range rows {
+		line := fmt.Sprintf("%*d %*d %*d %*d", width, r.counts.lines, width, r.counts.words,
+			width, r.counts.chars(cfg.chars), width, r.counts.bytes)
+		if records {
+			line = fmt.Sprintf("%*d", width, r.counts.records)
+		}
+		if r.name != "" {
+			line += " " + r.name
+		}
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// newSplitter returns a function creating the bufio.SplitFunc set by the
+// delim or pattern options in cfg, or nil if neither is set. A new
+// SplitFunc is needed for every bufio.Scanner, as they can keep state.
+func newSplitter(cfg config) (func() bufio.SplitFunc, error) {
+	switch {
+	case cfg.delim != "" && cfg.pattern != "":
+		return nil, fmt.Errorf("%w: set either a delimiter or a pattern", ErrInvalidSplit)
+	case cfg.delim != "":
+		delim, err := parseDelimiter(cfg.delim)
+		if err != nil {
+			return nil, err
+		}
+		return func() bufio.SplitFunc { return splitDelimiter(delim) }, nil
+	case cfg.pattern != "":
+		re, err := regexp.Compile(cfg.pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSplit, err)
+		}
+		return func() bufio.SplitFunc { return splitRegexp(re) }, nil
+	}
+
+	return nil, nil
+}
+
+// bareQuoteRe matches the escape sequences and double quotes of a
+// delimiter, to escape the quotes before unquoting it.
+var bareQuoteRe = regexp.MustCompile(`\\.|"`)
+
+// parseDelimiter interprets the Go escape sequences in s, like \t or
+// \x00, so any byte sequence can be given as a flag. \0 is accepted as a
+// shorthand for the NUL byte. Delimiters without escape sequences, such
+// as " or a lone \, are used as they are.
+func parseDelimiter(s string) ([]byte, error) {
+	switch {
+	case s == `\0`:
+		return []byte{0}, nil
+	case s == `\` || !strings.Contains(s, `\`):
+		return []byte(s), nil
+	}
+
+	quoted := bareQuoteRe.ReplaceAllStringFunc(s, func(m string) string {
+		if m == `"` {
+			return `\"`
+		}
+		return m
+	})
+	d, err := strconv.Unquote(`"` + quoted + `"`)
+	if err != nil {
+		return nil, fmt.Errorf("%w: delimiter %q", ErrInvalidSplit, s)
+	}
+
+	return []byte(d), nil
+}
+
+// splitDelimiter returns a bufio.SplitFunc splitting the input into
+// records ending with delim. Like lines, a final record without a
+// delimiter is returned too, and consecutive delimiters return empty
+// records.
+func splitDelimiter(delim []byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := bytes.Index(data, delim); i >= 0 {
+			return i + len(delim), data[:i], nil
+		}
+		// Return the final record, which has no delimiter.
+		if atEOF {
+			return len(data), data, nil
+		}
+
+		// Request more data.
+		return 0, nil, nil
+	}
+}
+
+// splitRegexp returns a bufio.SplitFunc returning every non-empty match
+// of re, like grep -o. Matches are searched line by line, so anchors
+// like ^ and $ work, and matches never span lines. The returned
+// SplitFunc keeps track of the line being split, so it must only be
+// used by a single bufio.Scanner.
+func splitRegexp(re *regexp.Regexp) bufio.SplitFunc {
+	var (
+		matches [][]int // matches left in the current line
+		pos     int     // bytes of the current line already consumed
+		lineLen int     // length of the current line, 0 between lines
+	)
+
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		// Lines without matches left are skipped within this call, as the
+		// scanner stops at EOF when no token is returned.
+		for {
+			rest := data[advance:]
+
+			if lineLen == 0 {
+				if len(rest) == 0 {
+					return advance, nil, nil
+				}
+
+				i := bytes.IndexByte(rest, '\n')
+				switch {
+				case i >= 0:
+					lineLen = i + 1
+				case atEOF:
+					lineLen = len(rest)
+				default:
+					// Request more data.
+					return advance, nil, nil
+				}
+
+				matches = matches[:0]
+				for _, m := range re.FindAllIndex(trimEOL(rest[:lineLen]), -1) {
+					if m[0] < m[1] {
+						matches = append(matches, m)
+					}
+				}
+				pos = 0
+			}
+
+			if len(matches) == 0 {
+				advance += lineLen - pos
+				lineLen = 0
+				continue
+			}
+
+			m := matches[0]
+			matches = matches[1:]
+			token = rest[m[0]-pos : m[1]-pos]
+			advance += m[1] - pos
+			pos = m[1]
+
+			return advance, token, nil
+		}
+	}
+}
+
+// trimEOL drops the line ending of a line, so it isn't matched by $.
+func trimEOL(line []byte) []byte {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r"))
+}
+
+// maxTokenSize is the size of the longest token newTokenScanner holds.
+// Records, and the lines regular expressions are matched on, can't be cut
+// in chunks like the lines of count, so they're only bounded by memory.
+const maxTokenSize = math.MaxInt
+
+// newTokenScanner returns a scanner splitting r with split,
+// returning tokens of any length.
+func newTokenScanner(r io.Reader, split bufio.SplitFunc) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), maxTokenSize)
+	scanner.Split(split)
+
+	return scanner
+}
+
+// countRecords returns the number of tokens given by io.Reader when
+// split with split.
+func countRecords(r io.Reader, split bufio.SplitFunc) (int, error) {
+	scanner := newTokenScanner(r, split)
+
+	n := 0
+	for scanner.Scan() {
+		n++
+	}
+
+	return n, scanner.Err()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// tokens returns every token of input split with split. The input is
+// read one byte at a time, so tokens are split across reads.
+func tokens(t *testing.T, input string, split bufio.SplitFunc) []string {
+	t.Helper()
+
+	scanner := bufio.NewScanner(iotest.OneByteReader(strings.NewReader(input)))
+	scanner.Split(split)
+
+	res := []string{}
+	for scanner.Scan() {
+		res = append(res, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestSplitDelimiter(t *testing.T) {
+	testCases := []struct {
+		name  string
+		delim string
+		input string
+		exp   []string
+	}{
+		{name: "Semicolon", delim: ";", input: "a;b;c", exp: []string{"a", "b", "c"}},
+		{name: "TrailingDelimiter", delim: ";", input: "a;b;", exp: []string{"a", "b"}},
+		{name: "EmptyRecords", delim: ";", input: "a;;b", exp: []string{"a", "", "b"}},
+		{name: "NUL", delim: "\x00", input: "one\x00two words\x00", exp: []string{"one", "two words"}},
+		{name: "MultiByte", delim: "--", input: "a-b--c", exp: []string{"a-b", "c"}},
+		{name: "Empty", delim: ";", input: "", exp: []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := tokens(t, tc.input, splitDelimiter([]byte(tc.delim)))
+			if !reflect.DeepEqual(tc.exp, res) {
+				t.Errorf("Exp %q, got %q\n", tc.exp, res)
+			}
+		})
+	}
+}
+
+func TestSplitRegexp(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pattern string
+		input   string
+		exp     []string
+	}{
+		{name: "Numbers", pattern: `[0-9]+`, input: "a1 b22\nc333\n", exp: []string{"1", "22", "333"}},
+		{name: "AnchorStart", pattern: `^\w+`, input: "ERROR x y\nINFO z\n", exp: []string{"ERROR", "INFO"}},
+		{name: "AnchorEnd", pattern: `\w+$`, input: "a b\r\nc d", exp: []string{"b", "d"}},
+		{name: "NoMatch", pattern: `x`, input: "abc\ndef\n", exp: []string{}},
+		{name: "EmptyMatches", pattern: `a*`, input: "baab\n", exp: []string{"aa"}},
+		{name: "NoSpanLines", pattern: `b\s+c`, input: "a b\nc d b  c\n", exp: []string{"b  c"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := tokens(t, tc.input, splitRegexp(regexp.MustCompile(tc.pattern)))
+			if !reflect.DeepEqual(tc.exp, res) {
+				t.Errorf("Exp %q, got %q\n", tc.exp, res)
+			}
+		})
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	testCases := []struct {
+		input  string
+		exp    []byte
+		expErr error
+	}{
+		{input: ";", exp: []byte(";")},
+		{input: `\0`, exp: []byte{0}},
+		{input: `\x00`, exp: []byte{0}},
+		{input: `\t`, exp: []byte("\t")},
+		{input: `\r\n`, exp: []byte("\r\n")},
+		{input: `"`, exp: []byte(`"`)},
+		{input: `\`, exp: []byte(`\`)},
+		{input: `"\t"`, exp: []byte("\"\t\"")},
+		{input: `\"`, exp: []byte(`"`)},
+		{input: `\q`, expErr: ErrInvalidSplit},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			res, err := parseDelimiter(tc.input)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expected error %q, got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(tc.exp, res) {
+				t.Errorf("Exp %q, got %q\n", tc.exp, res)
+			}
+		})
+	}
+}
+
+func TestRunRecords(t *testing.T) {
+	testCases := []struct {
+		name   string
+		stdin  string
+		cfg    config
+		exp    string
+		expErr error
+	}{
+		{name: "Delimiter", stdin: "a;b;c;", cfg: config{delim: ";"}, exp: "3\n"},
+		{name: "NUL", stdin: "x\x00y\x00", cfg: config{delim: `\0`}, exp: "2\n"},
+		{name: "Regexp", stdin: "GET /a 200\nGET /b 404\nPOST /c 500\n",
+			cfg: config{pattern: ` [45][0-9]{2}$`}, exp: "2\n"},
+		{name: "Table", stdin: "a;b", cfg: config{delim: ";", all: true}, exp: "2\n"},
+		{name: "Freq", stdin: "GET /a\nGET /b\nPOST /c\n",
+			cfg: config{pattern: `^[A-Z]+`, freq: freqConfig{top: 2, format: formatText}},
+			exp: "2 GET\n1 POST\n"},
+		{name: "Both", stdin: "a", cfg: config{delim: ";", pattern: "a"}, expErr: ErrInvalidSplit},
+		{name: "BadPattern", stdin: "a", cfg: config{pattern: "("}, expErr: ErrInvalidSplit},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			err := run(nil, strings.NewReader(tc.stdin), &out, tc.cfg)
+			if tc.expErr != nil {
+				if !errors.Is(err, tc.expErr) {
+					t.Errorf("Expected error %q, got %q", tc.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.exp {
+				t.Errorf("Exp %q, got %q\n", tc.exp, out.String())
+			}
+		})
+	}
+}